@@ -1,56 +1,166 @@
 package configuration
 
 import (
+	"fmt"
 	"os"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+// Configuration is the server of a target, several targets being stored in
+// the same file
 type Configuration struct {
-	LastUpdateDate string `yaml:"last-update-date"`
-	Hostname       string `yaml:"host"`
-	Port           int    `yaml:"port"`
-	Username       string `yaml:"user"`
-	MaxConnections int    `yaml:"max-concurrent-connections"`
-	ServerFolder   string `yaml:"server-folder"`
-	Protocol       string `yaml:"protocol"`
-	DebugMode      bool   `yaml:"debug-mode"`
+	// Target names the configuration, it's the key it's stored under
+	Target string `yaml:"-"`
+	// LastUpdateDate is when the last publish completed, as recorded before the
+	// snapshot had the published state of every file. It's only read to upgrade
+	// older snapshots.
+	LastUpdateDate string `yaml:"last-update-date,omitempty"`
+
+	Hostname          string `yaml:"host"`
+	Port              int    `yaml:"port"`
+	Username          string `yaml:"user"`
+	MaxConnections    int    `yaml:"max-concurrent-connections"`
+	ServerFolder      string `yaml:"server-folder"`
+	Protocol          string `yaml:"protocol"`
+	DebugMode         bool   `yaml:"debug-mode"`
+	PruneEmptyFolders bool   `yaml:"prune-empty-folders"`
+
+	// Transient failures are retried, waiting exponentially longer from
+	// RetryBaseDelay up to RetryMaxDelay, randomly shifted by RetryJitter percent
+	Retries        int           `yaml:"retries"`
+	RetryBaseDelay time.Duration `yaml:"retry-base-delay"`
+	RetryMaxDelay  time.Duration `yaml:"retry-max-delay"`
+	RetryJitter    int           `yaml:"retry-jitter-percent"`
+
+	// Transfer rates in KiB/s shared by all the workers, 0 meaning unlimited.
+	// Upload and download limits take precedence over the global one.
+	BandwidthLimit int `yaml:"bwlimit"`
+	UploadLimit    int `yaml:"upload-bwlimit"`
+	DownloadLimit  int `yaml:"download-bwlimit"`
+
+	// Options set from the command line for a single run, never stored
+	DryRun   bool `yaml:"-"`
+	FailFast bool `yaml:"-"`
+	Verify   bool `yaml:"-"`
+	// FanOut is set when several targets are published to at once, their runs
+	// sharing the terminal
+	FanOut bool `yaml:"-"`
 }
 
 const Filename = ".fileTransfer.config.yaml"
 
-func Read() (*Configuration, error) {
+// DefaultTarget names the server of configuration files written before
+// targets existed, and the target created by init when none is given
+const DefaultTarget = "default"
+
+var targetPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// file is the content of the configuration file
+type file struct {
+	DefaultTarget string                   `yaml:"default-target"`
+	Targets       map[string]Configuration `yaml:"targets"`
+}
+
+// readFile decodes every target over the defaults of New, so that settings
+// missing from older or hand written files keep their default value
+func readFile() (*file, error) {
 	content, err := os.ReadFile(Filename)
 	if err != nil {
 		return nil, err
 	}
-	var config Configuration
-	err = yaml.Unmarshal(content, &config)
+	var raw struct {
+		DefaultTarget string               `yaml:"default-target"`
+		Targets       map[string]yaml.Node `yaml:"targets"`
+	}
+	err = yaml.Unmarshal(content, &raw)
 	if err != nil {
 		return nil, err
 	}
-	return &config, nil
+	f := file{raw.DefaultTarget, map[string]Configuration{}}
+	if len(raw.Targets) == 0 {
+		// a single server used to be configured at the top level
+		config := New()
+		err = yaml.Unmarshal(content, &config)
+		if err != nil {
+			return nil, err
+		}
+		f = file{DefaultTarget, map[string]Configuration{DefaultTarget: config}}
+	}
+	for name, node := range raw.Targets {
+		config := New()
+		err = node.Decode(&config)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read target %s: %v", name, err)
+		}
+		f.Targets[name] = config
+	}
+	return &f, nil
 }
 
-func epochTime() string {
-	return time.Date(1970, time.January, 01, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+func (f *file) names() []string {
+	names := []string{}
+	for name := range f.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
-func currentTime() string {
-	return time.Now().Format(time.RFC3339)
+// Read returns the configuration of a target, the default one if target is empty
+func Read(target string) (*Configuration, error) {
+	f, err := readFile()
+	if err != nil {
+		return nil, err
+	}
+	if target == "" {
+		target = f.DefaultTarget
+	}
+	config, ok := f.Targets[target]
+	if !ok {
+		return nil, fmt.Errorf("unknown target %q, configured targets: %s", target, strings.Join(f.names(), ", "))
+	}
+	config.Target = target
+	return &config, nil
 }
 
-func New() Configuration {
-	return Configuration{epochTime(), "localhost", 22, "test", 3, ".", "sftp", false}
+// Targets returns the names of the configured targets
+func Targets() ([]string, error) {
+	f, err := readFile()
+	if err != nil {
+		return nil, err
+	}
+	return f.names(), nil
 }
 
-func (c *Configuration) UpdateTime() {
-	(*c).LastUpdateDate = currentTime()
+func New() Configuration {
+	return Configuration{Target: DefaultTarget, Hostname: "localhost", Port: 22, Username: "test",
+		MaxConnections: 3, ServerFolder: ".", Protocol: "sftp", DebugMode: false,
+		Retries: 3, RetryBaseDelay: time.Second, RetryMaxDelay: 30 * time.Second, RetryJitter: 20}
 }
 
-func (c *Configuration) Store() error {
-	yamlData, err := yaml.Marshal(c)
+// Store adds the target to the configuration file, or replaces it, keeping the
+// other targets. The first target stored becomes the default one, unless
+// isDefault is given for another one later.
+func (c *Configuration) Store(isDefault bool) error {
+	if !targetPattern.MatchString(c.Target) {
+		return fmt.Errorf("invalid target name %q, only letters, digits, '-' and '_' are allowed", c.Target)
+	}
+	f, err := readFile()
+	if os.IsNotExist(err) {
+		f = &file{Targets: map[string]Configuration{}}
+	} else if err != nil {
+		return err
+	}
+	f.Targets[c.Target] = *c
+	if f.DefaultTarget == "" || isDefault {
+		f.DefaultTarget = c.Target
+	}
+	yamlData, err := yaml.Marshal(f)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,187 @@
+package configuration
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+// inTempDir runs the rest of the test from an empty working copy
+func inTempDir(t *testing.T) {
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+}
+
+const legacyFile = `host: ftp.example.com
+port: 21
+user: alice
+max-concurrent-connections: 5
+server-folder: /www
+protocol: FTP
+`
+
+const targetsFile = `default-target: staging
+targets:
+  production:
+    host: www.example.com
+    port: 22
+    user: deploy
+    protocol: SFTP
+  staging:
+    host: staging.example.com
+    port: 2222
+    user: deploy
+    protocol: SFTP
+`
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		target   string
+		hostname string
+		port     int
+		isError  bool
+	}{
+		{"legacy file", legacyFile, "", "ftp.example.com", 21, false},
+		{"legacy file by name", legacyFile, DefaultTarget, "ftp.example.com", 21, false},
+		{"legacy file other target", legacyFile, "production", "", 0, true},
+		{"default target", targetsFile, "", "staging.example.com", 2222, false},
+		{"named target", targetsFile, "production", "www.example.com", 22, false},
+		{"unknown target", targetsFile, "test", "", 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inTempDir(t)
+			if err := os.WriteFile(Filename, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			config, err := Read(test.target)
+			if test.isError {
+				if err == nil {
+					t.Errorf("Read(%q) = %+v, want an error", test.target, config)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Hostname != test.hostname || config.Port != test.port {
+				t.Errorf("Read(%q) = %s:%d, want %s:%d", test.target, config.Hostname, config.Port, test.hostname, test.port)
+			}
+			if test.target != "" && config.Target != test.target {
+				t.Errorf("Read(%q).Target = %q", test.target, config.Target)
+			}
+		})
+	}
+}
+
+func TestReadDefaultsMissingSettings(t *testing.T) {
+	for _, content := range []string{legacyFile, targetsFile} {
+		inTempDir(t)
+		if err := os.WriteFile(Filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		config, err := Read("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defaults := New()
+		if config.Retries != defaults.Retries || config.RetryBaseDelay != defaults.RetryBaseDelay ||
+			config.RetryMaxDelay != defaults.RetryMaxDelay || config.RetryJitter != defaults.RetryJitter {
+			t.Errorf("retry settings missing from %s = %d, %v, %v, %d%%, want the defaults", config.Target,
+				config.Retries, config.RetryBaseDelay, config.RetryMaxDelay, config.RetryJitter)
+		}
+	}
+}
+
+func TestStoreMigratesLegacyFile(t *testing.T) {
+	inTempDir(t)
+	if err := os.WriteFile(Filename, []byte(legacyFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := Read("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mirror := New()
+	mirror.Target = "mirror"
+	mirror.Hostname = "mirror.example.com"
+	if err = mirror.Store(false); err != nil {
+		t.Fatal(err)
+	}
+
+	targets, err := Targets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{DefaultTarget, "mirror"}; !reflect.DeepEqual(targets, expected) {
+		t.Errorf("Targets() = %v, want %v", targets, expected)
+	}
+	config, err := Read("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, legacy) {
+		t.Errorf("default target after storing another one = %+v, want %+v", config, legacy)
+	}
+	config, err = Read("mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*config, mirror) {
+		t.Errorf("Read(mirror) = %+v, want %+v", *config, mirror)
+	}
+}
+
+func TestStore(t *testing.T) {
+	tests := []struct {
+		name          string
+		targets       []string
+		isDefault     []bool
+		defaultTarget string
+		isError       bool
+	}{
+		{"first target becomes the default", []string{"a", "b"}, []bool{false, false}, "a", false},
+		{"default given later", []string{"a", "b"}, []bool{false, true}, "b", false},
+		{"stored again", []string{"a", "b", "a"}, []bool{false, true, false}, "b", false},
+		{"invalid name", []string{"a b"}, []bool{false}, "", true},
+		{"empty name", []string{""}, []bool{false}, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inTempDir(t)
+			var err error
+			for i, target := range test.targets {
+				config := New()
+				config.Target = target
+				if err = config.Store(test.isDefault[i]); err != nil {
+					break
+				}
+			}
+			if test.isError {
+				if err == nil {
+					t.Error("Store() accepted an invalid target name")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			config, err := Read("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Target != test.defaultTarget {
+				t.Errorf("default target = %q, want %q", config.Target, test.defaultTarget)
+			}
+		})
+	}
+}
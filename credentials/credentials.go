@@ -0,0 +1,58 @@
+// Package credentials finds the password of a server without prompting when
+// it's available elsewhere, so that commands can run unattended.
+package credentials
+
+import (
+	"fileTransfer/configuration"
+	"fileTransfer/protocols/transport"
+	"fileTransfer/terminal"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// EnvironmentVariable holds the password, taking precedence over everything else
+const EnvironmentVariable = "FILETRANSFER_PASSWORD"
+
+// Password returns the password of a target, looked for the first time it's
+// called in this order: the FILETRANSFER_PASSWORD environment variable, the
+// first line of passwordFile if given, the entry of ~/.netrc matching the host
+// and user, then the prompt. It's only called when the server asks for a
+// password, so key based SFTP authentication never prompts.
+func Password(config configuration.Configuration, passwordFile string) transport.Password {
+	var once sync.Once
+	var password string
+	var err error
+	return func() (string, error) {
+		once.Do(func() {
+			password, err = resolve(config, passwordFile)
+		})
+		return password, err
+	}
+}
+
+func resolve(config configuration.Configuration, passwordFile string) (string, error) {
+	if password, ok := os.LookupEnv(EnvironmentVariable); ok {
+		return password, nil
+	}
+	if passwordFile != "" {
+		content, err := os.ReadFile(passwordFile)
+		if err != nil {
+			return "", fmt.Errorf("cannot read password file (%s): %v", passwordFile, err)
+		}
+		return strings.TrimRight(strings.SplitN(string(content), "\n", 2)[0], "\r"), nil
+	}
+	password, ok, err := netrcPassword(config.Hostname, config.Username)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return password, nil
+	}
+	if !terminal.IsTerminal(os.Stdin) {
+		return "", fmt.Errorf("no password for %s@%s: set %s, use --password-file or add it to ~/.netrc",
+			config.Username, config.Hostname, EnvironmentVariable)
+	}
+	return terminal.InputPassword(fmt.Sprintf("Password for %s@%s:", config.Username, config.Hostname)), nil
+}
@@ -0,0 +1,97 @@
+package credentials
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// netrcEntry is a machine of a netrc file, or its default entry when machine is empty
+type netrcEntry struct {
+	machine  string
+	login    string
+	password string
+}
+
+// netrcFilename is $NETRC, or ~/.netrc
+func netrcFilename() string {
+	if filename := os.Getenv("NETRC"); filename != "" {
+		return filename
+	}
+	return filepath.Join(os.Getenv("HOME"), ".netrc")
+}
+
+// netrcPassword returns the password of the first entry matching host, and
+// user if the entry has a login, falling back to the default entry
+func netrcPassword(host string, user string) (string, bool, error) {
+	filename := netrcFilename()
+	content, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, fmt.Errorf("cannot read netrc file (%s): %v", filename, err)
+	}
+	fallback, hasFallback := "", false
+	for _, entry := range parseNetrc(string(content)) {
+		if entry.login != "" && entry.login != user {
+			continue
+		}
+		if entry.machine == host {
+			return entry.password, true, nil
+		}
+		if entry.machine == "" && !hasFallback {
+			fallback, hasFallback = entry.password, true
+		}
+	}
+	return fallback, hasFallback, nil
+}
+
+// parseNetrc reads the machine and default entries of a netrc file, skipping
+// macro definitions, which run until the next empty line
+func parseNetrc(content string) []netrcEntry {
+	entries := []netrcEntry{}
+	var entry *netrcEntry
+	inMacro := false
+	for _, line := range strings.Split(content, "\n") {
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields); i++ {
+			value := ""
+			if i+1 < len(fields) {
+				value = fields[i+1]
+			}
+			switch fields[i] {
+			case "machine":
+				entries = append(entries, netrcEntry{machine: value})
+				entry = &entries[len(entries)-1]
+				i++
+			case "default":
+				entries = append(entries, netrcEntry{})
+				entry = &entries[len(entries)-1]
+			case "login":
+				if entry != nil {
+					entry.login = value
+				}
+				i++
+			case "password":
+				if entry != nil {
+					entry.password = value
+				}
+				i++
+			case "account":
+				i++
+			case "macdef":
+				inMacro = true
+				i = len(fields)
+			}
+		}
+	}
+	return entries
+}
@@ -0,0 +1,89 @@
+package credentials
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const netrcContent = `# shared accounts
+machine ftp.example.com login alice password alicepw
+machine ftp.example.com login bob password bobpw
+
+machine sftp.example.com
+	login carol
+	password carolpw
+	account unused
+
+machine anyuser.example.com password anypw
+
+macdef init
+machine macro.example.com login alice password macropw
+
+machine after.example.com login alice password afterpw
+default login alice password defaultpw
+default password secondpw
+`
+
+func TestNetrcPassword(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(filename, []byte(netrcContent), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NETRC", filename)
+
+	tests := []struct {
+		name     string
+		host     string
+		user     string
+		password string
+		found    bool
+	}{
+		{"machine and login", "ftp.example.com", "alice", "alicepw", true},
+		{"other login of the machine", "ftp.example.com", "bob", "bobpw", true},
+		{"entry on several lines", "sftp.example.com", "carol", "carolpw", true},
+		{"entry without login", "anyuser.example.com", "dave", "anypw", true},
+		{"macro content is not an entry", "macro.example.com", "alice", "defaultpw", true},
+		{"entry after a macro", "after.example.com", "alice", "afterpw", true},
+		{"default entry", "unknown.example.com", "alice", "defaultpw", true},
+		{"default entry without login", "unknown.example.com", "erin", "secondpw", true},
+		{"unknown login falls back to default", "sftp.example.com", "erin", "secondpw", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			password, found, err := netrcPassword(test.host, test.user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if password != test.password || found != test.found {
+				t.Errorf("netrcPassword(%q, %q) = %q, %v, want %q, %v",
+					test.host, test.user, password, found, test.password, test.found)
+			}
+		})
+	}
+}
+
+func TestNetrcPasswordNotFound(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(filename, []byte("machine ftp.example.com login alice password alicepw\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NETRC", filename)
+
+	for _, user := range []string{"bob", ""} {
+		if password, found, err := netrcPassword("ftp.example.com", user); err != nil || found {
+			t.Errorf("netrcPassword(%q) = %q, %v, %v, want not found", user, password, found, err)
+		}
+	}
+	if _, found, err := netrcPassword("sftp.example.com", "alice"); err != nil || found {
+		t.Errorf("netrcPassword of another host = %v, %v, want not found", found, err)
+	}
+}
+
+func TestNetrcPasswordMissingFile(t *testing.T) {
+	t.Setenv("NETRC", filepath.Join(t.TempDir(), "missing"))
+
+	if password, found, err := netrcPassword("ftp.example.com", "alice"); err != nil || found {
+		t.Errorf("netrcPassword = %q, %v, %v, want not found without error", password, found, err)
+	}
+}
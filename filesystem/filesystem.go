@@ -1,43 +1,110 @@
 package filesystem
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fileTransfer/configuration"
-	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type FileData struct {
-	AbsolutePath string    `yaml:"absolute-path"`
-	RelativePath string    `yaml:"relative-path"`
-	Size         int64     `yaml:"size"`
-	ModTime      time.Time `yaml:"modified"`
-	IsDeleted    bool      `yaml:"deleted"`
+	AbsolutePath  string    `yaml:"absolute-path"`
+	RelativePath  string    `yaml:"relative-path"`
+	Size          int64     `yaml:"size"`
+	ModTime       time.Time `yaml:"modified"`
+	IsDeleted     bool      `yaml:"deleted"`
+	Hash          string    `yaml:"sha256,omitempty"`
+	RemoteModTime time.Time `yaml:"remote-modified,omitempty"`
 }
 
-const fileSystemFilename = ".fileTransfer.files"
+// stateFilePrefix starts the names of the configuration and of the sync state
+// of every target, which are never transferred
+const stateFilePrefix = ".fileTransfer."
 
-var ignoredFiles []string = []string{configuration.Filename, fileSystemFilename}
+// stateFilename names a sync state file of a target, the default target keeping
+// the name it had before targets existed
+func stateFilename(target string, kind string) string {
+	if target == "" || target == configuration.DefaultTarget {
+		return stateFilePrefix + kind
+	}
+	return stateFilePrefix + target + "." + kind
+}
+
+const temporarySuffix = ".fileTransfer.tmp"
+
+// TemporaryName returns the hidden sibling a file is written to before being
+// renamed into place. It works with both local and slash separated remote paths.
+func TemporaryName(filename string) string {
+	i := strings.LastIndexAny(filename, "/"+string(filepath.Separator)) + 1
+	return filename[:i] + "." + filename[i:] + temporarySuffix
+}
+
+func isTemporary(filename string) bool {
+	return strings.HasSuffix(filename, temporarySuffix)
+}
+
+var conflictCopyPattern = regexp.MustCompile(`\.conflict-\d{14}(\.[^/\\]*)?$`)
+
+// IsConflictCopy tells whether filename is the local version sync kept of a
+// file modified on both sides. Conflict copies only exist locally, so they
+// are neither published nor deleted because they are missing on the server.
+func IsConflictCopy(filename string) bool {
+	return conflictCopyPattern.MatchString(filename)
+}
 
 func shouldIgnoreFile(filename string) bool {
-	shouldInclude := true
-	for _, ignoredFile := range ignoredFiles {
-		if filepath.Base(filename) == ignoredFile {
-			shouldInclude = false
-			break
-		}
-	}
-	return shouldInclude
+	return !isTemporary(filename) && !IsConflictCopy(filename) && !strings.HasPrefix(filepath.Base(filename), stateFilePrefix)
 }
 
 type Filesystem map[string]FileData
 
-func CreateAndStoreFileList() (Filesystem, error) {
+// FileHash returns the hex encoded sha256 of a local file
+func FileHash(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// CreateFileList walks the current directory and compares it with the stored
+// snapshot of a target, i.e. the files its server is known to have, to find
+// deleted files without storing anything.
+// Files whose size and modification time match the snapshot keep their stored
+// hash instead of being read again.
+func CreateFileList(target string) (Filesystem, error) {
+	lists, err := CreateFileLists([]string{target})
+	if err != nil {
+		return nil, err
+	}
+	return lists[target], nil
+}
+
+// CreateFileLists walks the current directory once for several targets,
+// returning the file list of each target as CreateFileList does
+func CreateFileLists(targets []string) (map[string]Filesystem, error) {
 	directoryPath := "." // Always check files in current directory
 	newfs := Filesystem{}
+	// check if old "filesystem" files were created
+	previousFilesystems := map[string]Filesystem{}
+	for _, target := range targets {
+		if previousFilesystem, err := ReadFileList(target); err == nil {
+			previousFilesystems[target] = previousFilesystem
+		}
+	}
+	ignoreList := &IgnoreList{}
 	err := filepath.Walk(directoryPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -46,8 +113,31 @@ func CreateAndStoreFileList() (Filesystem, error) {
 		if err != nil {
 			return err
 		}
-		if shouldIgnoreFile(absPath) && !info.IsDir() {
-			fileItem := FileData{absPath, path, info.Size(), info.ModTime(), false}
+		// the ignore file of a folder is loaded before walking its content
+		relativePath := filepath.ToSlash(path)
+		if relativePath != "." && ignoreList.Match(relativePath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return ignoreList.load(relativePath)
+		}
+		if shouldIgnoreFile(absPath) {
+			fileItem := FileData{AbsolutePath: absPath, RelativePath: path, Size: info.Size(), ModTime: info.ModTime()}
+			for _, previousFilesystem := range previousFilesystems {
+				previousItem, ok := previousFilesystem[absPath]
+				if ok && previousItem.Hash != "" && previousItem.Size == fileItem.Size && previousItem.ModTime.Equal(fileItem.ModTime) {
+					fileItem.Hash = previousItem.Hash
+					break
+				}
+			}
+			if fileItem.Hash == "" {
+				if fileItem.Hash, err = FileHash(absPath); err != nil {
+					return err
+				}
+			}
 			newfs[absPath] = fileItem
 		}
 		return nil
@@ -56,21 +146,26 @@ func CreateAndStoreFileList() (Filesystem, error) {
 		return nil, err
 	}
 
-	// check if old "filesystem" file was created and find deleted files
-	previousFilesystem, err := readFilesystemFile()
-	if err == nil {
-		deletedFiles := previousFilesystem.Remove(newfs)
+	lists := map[string]Filesystem{}
+	for _, target := range targets {
+		targetfs := Filesystem{}
+		for filename, fileData := range newfs {
+			targetfs[filename] = fileData
+		}
+		// find deleted files
+		deletedFiles := previousFilesystems[target].Remove(newfs)
 		for filename, fileData := range deletedFiles {
+			// newly ignored files are left alone on the server
+			if ignoreList.Match(filepath.ToSlash(fileData.RelativePath), false) {
+				continue
+			}
 			fileData.IsDeleted = true
 			fileData.ModTime = time.Now()
-			newfs[filename] = fileData
+			targetfs[filename] = fileData
 		}
+		lists[target] = targetfs
 	}
-	err = newfs.storeFile()
-	if err != nil {
-		return nil, fmt.Errorf("cannot store filesystem changes: %v", err)
-	}
-	return newfs, nil
+	return lists, nil
 }
 
 func (fs Filesystem) Add(other Filesystem) Filesystem {
@@ -105,44 +200,78 @@ func (fs Filesystem) Remove(other Filesystem) Filesystem {
 	return newfs
 }
 
-func (fs Filesystem) List(updatedSince string) ([]FileData, error) {
-	lastUpdate, err := time.Parse(time.RFC3339, updatedSince)
-	if err != nil {
-		return nil, err
+// HasChanged compares a file with the published state of the snapshot. The
+// content hash decides, so that touching a file doesn't make it change.
+func (fd FileData) HasChanged(published Filesystem) bool {
+	if fd.IsDeleted {
+		return true
+	}
+	publishedData, ok := published[fd.AbsolutePath]
+	if !ok || publishedData.IsDeleted {
+		return true
+	}
+	return fd.Hash != publishedData.Hash
+}
+
+// PublishedModTime is the modification time the server had for the file when
+// it was last transferred. Snapshots written before it was recorded only know
+// the local one.
+func (fd FileData) PublishedModTime() time.Time {
+	if fd.RemoteModTime.IsZero() {
+		return fd.ModTime
 	}
+	return fd.RemoteModTime
+}
+
+// List returns the files that differ from the published state
+func (fs Filesystem) List(published Filesystem) []FileData {
 	filesList := []FileData{}
 	for _, fileData := range fs {
-		if fileData.ModTime.After(lastUpdate) && shouldIgnoreFile(fileData.AbsolutePath) {
+		if fileData.HasChanged(published) && shouldIgnoreFile(fileData.AbsolutePath) {
 			filesList = append(filesList, fileData)
 		}
 	}
-	return filesList, nil
+	return filesList
 }
 
-// This method removes "deleted" file entries previously stored
-func (fs Filesystem) Clean() error {
-	toBeDeleted := []string{}
-	for filename, fileData := range fs {
-		if fileData.IsDeleted {
-			toBeDeleted = append(toBeDeleted, filename)
-		}
-	}
-	for _, filename := range toBeDeleted {
+// Publish records the current state of a file as the one the server has, along
+// with the server modification time. Deleted files are forgotten.
+func (fs Filesystem) Publish(current Filesystem, filename string, remoteModTime time.Time) {
+	fileData, ok := current[filename]
+	if !ok || fileData.IsDeleted {
 		delete(fs, filename)
+		return
+	}
+	fileData.RemoteModTime = remoteModTime
+	fs[filename] = fileData
+}
+
+// Refresh updates the local size and modification time of the published files
+// whose content didn't change, so that they aren't hashed again
+func (fs Filesystem) Refresh(current Filesystem) {
+	for filename, published := range fs {
+		fileData, ok := current[filename]
+		if !ok || fileData.IsDeleted || fileData.Hash != published.Hash {
+			continue
+		}
+		published.Size = fileData.Size
+		published.ModTime = fileData.ModTime
+		fs[filename] = published
 	}
-	return fs.storeFile()
 }
 
-func (fs Filesystem) storeFile() error {
+func (fs Filesystem) Store(target string) error {
 	yamlData, err := yaml.Marshal(fs)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(fileSystemFilename, yamlData, 0644)
+	return os.WriteFile(stateFilename(target, "files"), yamlData, 0644)
 }
 
-func readFilesystemFile() (*Filesystem, error) {
-	content, err := os.ReadFile(fileSystemFilename)
+// ReadFileList returns the snapshot of the files published to the server of a
+// target, or downloaded from it
+func ReadFileList(target string) (Filesystem, error) {
+	content, err := os.ReadFile(stateFilename(target, "files"))
 	if err != nil {
 		return nil, err
 	}
@@ -151,5 +280,44 @@ func readFilesystemFile() (*Filesystem, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &filesystem, nil
+	filesystem.upgrade(target)
+	return filesystem, nil
+}
+
+// upgrade fills in the hashes missing from a snapshot written before they were
+// recorded, for the files that are still the ones published then: same size and
+// modification time, and not modified after the last update date of the target.
+// The others keep no hash, so they're published again.
+func (fs Filesystem) upgrade(target string) {
+	lastUpdate, loaded := time.Time{}, false
+	for filename, fileData := range fs {
+		if fileData.Hash != "" || fileData.IsDeleted {
+			continue
+		}
+		if !loaded {
+			lastUpdate, loaded = lastUpdateDate(target), true
+		}
+		info, err := os.Stat(filename)
+		if err != nil || info.Size() != fileData.Size || !info.ModTime().Equal(fileData.ModTime) || fileData.ModTime.After(lastUpdate) {
+			continue
+		}
+		if fileData.Hash, err = FileHash(filename); err == nil {
+			fs[filename] = fileData
+		}
+	}
+}
+
+// lastUpdateDate returns when the last publish to a target completed, as
+// recorded before snapshots had the published state of every file, or the
+// zero time if it's unknown
+func lastUpdateDate(target string) time.Time {
+	config, err := configuration.Read(target)
+	if err != nil {
+		return time.Time{}
+	}
+	lastUpdate, err := time.Parse(time.RFC3339, config.LastUpdateDate)
+	if err != nil {
+		return time.Time{}
+	}
+	return lastUpdate
 }
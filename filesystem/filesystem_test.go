@@ -0,0 +1,128 @@
+package filesystem
+
+import (
+	"fileTransfer/configuration"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestHasChanged(t *testing.T) {
+	published := Filesystem{
+		"/work/a.txt":       {AbsolutePath: "/work/a.txt", Size: 2, Hash: "aa"},
+		"/work/deleted.txt": {AbsolutePath: "/work/deleted.txt", Size: 2, Hash: "dd", IsDeleted: true},
+	}
+	tests := []struct {
+		name     string
+		file     FileData
+		expected bool
+	}{
+		{"same content", FileData{AbsolutePath: "/work/a.txt", Size: 2, Hash: "aa"}, false},
+		{"touched", FileData{AbsolutePath: "/work/a.txt", Size: 2, Hash: "aa", ModTime: time.Now()}, false},
+		{"modified", FileData{AbsolutePath: "/work/a.txt", Size: 2, Hash: "ab"}, true},
+		{"deleted", FileData{AbsolutePath: "/work/a.txt", Size: 2, Hash: "aa", IsDeleted: true}, true},
+		{"never published", FileData{AbsolutePath: "/work/b.txt", Size: 2, Hash: "bb"}, true},
+		{"published as deleted", FileData{AbsolutePath: "/work/deleted.txt", Size: 2, Hash: "dd"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if changed := test.file.HasChanged(published); changed != test.expected {
+				t.Errorf("HasChanged() = %v, want %v", changed, test.expected)
+			}
+		})
+	}
+}
+
+// legacyFile is a file as published before snapshots recorded hashes
+type legacyFile struct {
+	name     string
+	content  string
+	modified time.Time // in the snapshot
+	current  time.Time // in the working copy
+}
+
+func TestUpgradeLegacySnapshot(t *testing.T) {
+	lastUpdate := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	before, after := lastUpdate.Add(-time.Hour), lastUpdate.Add(time.Hour)
+	legacyFiles := []legacyFile{
+		{"published.txt", "published", before, before},
+		{"sub/published.txt", "published", before, before},
+		{"edited.txt", "edited", before, after},
+		{"late.txt", "modified during the last publish", after, after},
+	}
+	tests := []struct {
+		name       string
+		config     string
+		modified   []string
+		unmodified []string
+	}{
+		{"last update date", "host: example.com\nlast-update-date: \"2026-01-01T12:00:00Z\"\n",
+			[]string{"edited.txt", "late.txt"}, []string{"published.txt", "sub/published.txt"}},
+		{"never published", "host: example.com\nlast-update-date: \"1970-01-01T00:00:00Z\"\n",
+			[]string{"edited.txt", "late.txt", "published.txt", "sub/published.txt"}, []string{}},
+		{"no last update date", "host: example.com\n",
+			[]string{"edited.txt", "late.txt", "published.txt", "sub/published.txt"}, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inTempDir(t)
+			if err := os.WriteFile(configuration.Filename, []byte(test.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			snapshot := Filesystem{}
+			for _, f := range legacyFiles {
+				absolutePath, err := filepath.Abs(f.name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err = os.MkdirAll(filepath.Dir(absolutePath), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err = os.WriteFile(absolutePath, []byte(f.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err = os.Chtimes(absolutePath, f.current, f.current); err != nil {
+					t.Fatal(err)
+				}
+				snapshot[absolutePath] = FileData{AbsolutePath: absolutePath, RelativePath: f.name, Size: int64(len(f.content)), ModTime: f.modified}
+			}
+			if err := snapshot.Store(configuration.DefaultTarget); err != nil {
+				t.Fatal(err)
+			}
+
+			changes, err := PendingChanges(configuration.DefaultTarget)
+			if err != nil {
+				t.Fatal(err)
+			}
+			modified := []string{}
+			for _, change := range changes {
+				if change.Type != Modified {
+					t.Errorf("%s %s, want modified", change.File.RelativePath, change.Type)
+				}
+				modified = append(modified, filepath.ToSlash(change.File.RelativePath))
+			}
+			if !reflect.DeepEqual(modified, test.modified) {
+				t.Errorf("modified files = %v, want %v", modified, test.modified)
+			}
+
+			// the hash of the files still published is filled in
+			upgraded, err := ReadFileList(configuration.DefaultTarget)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hashed := []string{}
+			for _, fileData := range upgraded {
+				if fileData.Hash != "" {
+					hashed = append(hashed, fileData.RelativePath)
+				}
+			}
+			sort.Strings(hashed)
+			if !reflect.DeepEqual(hashed, test.unmodified) {
+				t.Errorf("hashed files = %v, want %v", hashed, test.unmodified)
+			}
+		})
+	}
+}
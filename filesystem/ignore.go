@@ -0,0 +1,279 @@
+package filesystem
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Patterns of files that are never published nor downloaded, one per line, with
+// the same syntax as .gitignore files. Every folder may contain one, its
+// patterns being relative to that folder.
+const IgnoreFilename = ".fileTransferignore"
+
+type ignoreRule struct {
+	folder  string // slash separated, relative to the working directory, "" for the root
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+type IgnoreList struct {
+	rules []ignoreRule
+}
+
+// ReadIgnoreList loads the ignore file of the current directory and of every
+// sub-folder that isn't ignored itself.
+func ReadIgnoreList() (*IgnoreList, error) {
+	il := &IgnoreList{}
+	err := filepath.Walk(".", func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		relativePath := filepath.ToSlash(filename)
+		if relativePath != "." && il.Match(relativePath, true) {
+			return filepath.SkipDir
+		}
+		return il.load(relativePath)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return il, nil
+}
+
+// load adds the rules of the ignore file found in folder, if any
+func (il *IgnoreList) load(folder string) error {
+	f, err := os.Open(filepath.Join(filepath.FromSlash(folder), IgnoreFilename))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if folder == "." {
+		folder = ""
+	}
+	scanner := bufio.NewScanner(f)
+	for number := 1; scanner.Scan(); number++ {
+		rule, ok, err := parseIgnoreRule(folder, scanner.Text())
+		if err != nil {
+			return fmt.Errorf("invalid pattern in %s (line %d): %v", f.Name(), number, err)
+		}
+		if ok {
+			il.rules = append(il.rules, rule)
+		}
+	}
+	return scanner.Err()
+}
+
+func parseIgnoreRule(folder string, line string) (ignoreRule, bool, error) {
+	rule := ignoreRule{folder: folder}
+	line = strings.TrimRight(line, "\r")
+	// trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule, false, nil
+	}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule, false, nil
+	}
+
+	// patterns without a slash match at any depth, the others are relative to the folder
+	prefix := "^(?:.*/)?"
+	if strings.Contains(line, "/") {
+		prefix = "^"
+		line = strings.TrimPrefix(line, "/")
+	}
+	expression, err := globToRegexp(line)
+	if err != nil {
+		return rule, false, err
+	}
+	rule.pattern, err = regexp.Compile(prefix + expression + "$")
+	if err != nil {
+		return rule, false, err
+	}
+	return rule, true, nil
+}
+
+func globToRegexp(glob string) (string, error) {
+	var expression strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					// "**/" matches zero or more folders
+					i++
+					expression.WriteString("(?:.*/)?")
+				} else {
+					expression.WriteString(".*")
+				}
+			} else {
+				expression.WriteString("[^/]*")
+			}
+		case '?':
+			expression.WriteString("[^/]")
+		case '[':
+			class, length, err := bracketToRegexp(glob[i:])
+			if err != nil {
+				return "", err
+			}
+			if length == 0 {
+				expression.WriteString(`\[`)
+				continue
+			}
+			expression.WriteString(class)
+			i += length - 1
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				expression.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return expression.String(), nil
+}
+
+// posixClasses are the character classes gitignore patterns may use, as in [[:alpha:]]
+var posixClasses = map[string]bool{"alnum": true, "alpha": true, "blank": true, "cntrl": true, "digit": true,
+	"graph": true, "lower": true, "print": true, "punct": true, "space": true, "upper": true, "xdigit": true}
+
+// bracketToRegexp converts the bracket expression glob starts with, returning
+// its length in glob, or 0 if it isn't closed and '[' is a literal. As with git,
+// a ']' right after the opening bracket is a literal and reversed ranges match
+// nothing.
+func bracketToRegexp(glob string) (string, int, error) {
+	i := 1
+	negate := i < len(glob) && (glob[i] == '!' || glob[i] == '^')
+	if negate {
+		i++
+	}
+	var items strings.Builder
+	for first := true; i < len(glob); first = false {
+		if glob[i] == ']' && !first {
+			return bracketClass(items.String(), negate), i + 1, nil
+		}
+		if strings.HasPrefix(glob[i:], "[:") {
+			if end := strings.Index(glob[i+2:], ":]"); end >= 0 {
+				name := glob[i+2 : i+2+end]
+				if !posixClasses[name] {
+					return "", 0, fmt.Errorf("unknown character class [:%s:]", name)
+				}
+				items.WriteString("[:" + name + ":]")
+				i += end + 4
+				continue
+			}
+		}
+		low, size := bracketChar(glob[i:])
+		i += size
+		if i+1 < len(glob) && glob[i] == '-' && glob[i+1] != ']' {
+			high, size := bracketChar(glob[i+1:])
+			i += size + 1
+			if low <= high {
+				items.WriteString(quoteClassChar(low) + "-" + quoteClassChar(high))
+			}
+			continue
+		}
+		items.WriteString(quoteClassChar(low))
+	}
+	return "", 0, nil
+}
+
+// bracketChar decodes the possibly escaped character glob starts with
+func bracketChar(glob string) (rune, int) {
+	if glob[0] == '\\' && len(glob) > 1 {
+		r, size := utf8.DecodeRuneInString(glob[1:])
+		return r, size + 1
+	}
+	return utf8.DecodeRuneInString(glob)
+}
+
+func quoteClassChar(r rune) string {
+	if r < utf8.RuneSelf && r > ' ' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+		return `\` + string(r)
+	}
+	return string(r)
+}
+
+// bracketClass returns the regexp class of the items, a negated class never
+// matching a slash. A class left empty by reversed ranges matches nothing.
+func bracketClass(items string, negate bool) string {
+	switch {
+	case negate:
+		return "[^/" + items + "]"
+	case items == "":
+		return `[^\x00-\x{10FFFF}]`
+	}
+	return "[" + items + "]"
+}
+
+// matchRules applies the rules to relativePath alone, the last matching rule wins
+func (il *IgnoreList) matchRules(relativePath string, isDir bool) bool {
+	ignored := false
+	for _, rule := range il.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rulePath := relativePath
+		if rule.folder != "" {
+			if !strings.HasPrefix(relativePath, rule.folder+"/") {
+				continue
+			}
+			rulePath = strings.TrimPrefix(relativePath, rule.folder+"/")
+		}
+		if rule.pattern.MatchString(rulePath) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// Match tells if a slash separated path, relative to the working directory,
+// is ignored. As with git, files can't be re-included if a parent folder is
+// ignored.
+func (il *IgnoreList) Match(relativePath string, isDir bool) bool {
+	relativePath = path.Clean(relativePath)
+	folders := strings.Split(relativePath, "/")
+	for i := 1; i < len(folders); i++ {
+		if il.matchRules(strings.Join(folders[:i], "/"), true) {
+			return true
+		}
+	}
+	return il.matchRules(relativePath, isDir)
+}
+
+// Filter removes the ignored files and the tool's own files from list, using
+// their relative paths
+func (il *IgnoreList) Filter(list []FileData) []FileData {
+	filtered := []FileData{}
+	for _, fileData := range list {
+		if shouldIgnoreFile(fileData.RelativePath) && !il.Match(filepath.ToSlash(fileData.RelativePath), false) {
+			filtered = append(filtered, fileData)
+		}
+	}
+	return filtered
+}
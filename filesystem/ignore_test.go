@@ -0,0 +1,124 @@
+package filesystem
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// ignoreFile is the content of the ignore file of a folder, "" being the root
+type ignoreFile struct {
+	folder string
+	lines  string
+}
+
+func newIgnoreList(t *testing.T, ignoreFiles ...ignoreFile) *IgnoreList {
+	il := &IgnoreList{}
+	for _, f := range ignoreFiles {
+		for _, line := range strings.Split(f.lines, "\n") {
+			rule, ok, err := parseIgnoreRule(f.folder, line)
+			if err != nil {
+				t.Fatalf("parseIgnoreRule(%q): %v", line, err)
+			}
+			if ok {
+				il.rules = append(il.rules, rule)
+			}
+		}
+	}
+	return il
+}
+
+func TestIgnoreListMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []ignoreFile
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"name at root", []ignoreFile{{"", "*.log"}}, "debug.log", false, true},
+		{"name at any depth", []ignoreFile{{"", "*.log"}}, "a/b/debug.log", false, true},
+		{"star stops at slash", []ignoreFile{{"", "a/*.log"}}, "a/b/debug.log", false, false},
+		{"other extension", []ignoreFile{{"", "*.log"}}, "debug.txt", false, false},
+		{"question mark", []ignoreFile{{"", "file?.txt"}}, "file1.txt", false, true},
+		{"question mark needs a character", []ignoreFile{{"", "file?.txt"}}, "file.txt", false, false},
+		{"character class", []ignoreFile{{"", "file[0-9].txt"}}, "file7.txt", false, true},
+		{"negated character class", []ignoreFile{{"", "file[!0-9].txt"}}, "file7.txt", false, false},
+		{"unclosed bracket is literal", []ignoreFile{{"", "file[.txt"}}, "file[.txt", false, true},
+		{"caret negates a class", []ignoreFile{{"", "file[^0-9].txt"}}, "filea.txt", false, true},
+		{"leading bracket is literal", []ignoreFile{{"", "[]]x"}}, "]x", false, true},
+		{"leading bracket class", []ignoreFile{{"", "[]a]x"}}, "ax", false, true},
+		{"negated leading bracket", []ignoreFile{{"", "[!]]x"}}, "ax", false, true},
+		{"negated leading bracket is excluded", []ignoreFile{{"", "[!]]x"}}, "]x", false, false},
+		{"escaped bracket in class", []ignoreFile{{"", `[\]a]x`}}, "]x", false, true},
+		{"posix class", []ignoreFile{{"", "[[:alpha:]].txt"}}, "a.txt", false, true},
+		{"posix class excludes", []ignoreFile{{"", "[[:alpha:]].txt"}}, "1.txt", false, false},
+		{"posix class among characters", []ignoreFile{{"", "[_[:digit:]].txt"}}, "_.txt", false, true},
+		{"negated posix class", []ignoreFile{{"", "[![:digit:]].txt"}}, "1.txt", false, false},
+		{"reversed range matches nothing", []ignoreFile{{"", "[z-a].txt"}}, "z.txt", false, false},
+		{"negated reversed range", []ignoreFile{{"", "[!z-a].txt"}}, "z.txt", false, true},
+		{"dash at the end is literal", []ignoreFile{{"", "[a-].txt"}}, "-.txt", false, true},
+		{"regexp characters in class", []ignoreFile{{"", "[\\^$.|?*+()].txt"}}, "|.txt", false, true},
+		{"negated class excludes slash", []ignoreFile{{"", "a[!b]c"}}, "a/c", false, false},
+		{"non ascii class", []ignoreFile{{"", "[éè].txt"}}, "è.txt", false, true},
+		{"escaped star is literal", []ignoreFile{{"", `\*.txt`}}, "a.txt", false, false},
+		{"escaped star matches itself", []ignoreFile{{"", `\*.txt`}}, "*.txt", false, true},
+		{"comment", []ignoreFile{{"", "#debug.log"}}, "#debug.log", false, false},
+		{"escaped trailing space", []ignoreFile{{"", `name\ `}}, "name ", false, true},
+		{"trailing spaces trimmed", []ignoreFile{{"", "debug.log   "}}, "debug.log", false, true},
+		{"leading slash anchors", []ignoreFile{{"", "/build"}}, "build", true, true},
+		{"leading slash only at root", []ignoreFile{{"", "/build"}}, "src/build", true, false},
+		{"inner slash anchors", []ignoreFile{{"", "docs/build"}}, "src/docs/build", true, false},
+		{"double star prefix", []ignoreFile{{"", "**/logs"}}, "a/b/logs", true, true},
+		{"double star prefix at root", []ignoreFile{{"", "**/logs"}}, "logs", true, true},
+		{"double star in the middle", []ignoreFile{{"", "a/**/b"}}, "a/x/y/b", false, true},
+		{"double star matches no folder", []ignoreFile{{"", "a/**/b"}}, "a/b", false, true},
+		{"double star suffix", []ignoreFile{{"", "a/**"}}, "a/x/y.txt", false, true},
+		{"dir only matches folders", []ignoreFile{{"", "tmp/"}}, "tmp", true, true},
+		{"dir only skips files", []ignoreFile{{"", "tmp/"}}, "tmp", false, false},
+		{"content of ignored folder", []ignoreFile{{"", "tmp/"}}, "tmp/a.txt", false, true},
+		{"negation re-includes", []ignoreFile{{"", "*.log\n!keep.log"}}, "keep.log", false, false},
+		{"last rule wins", []ignoreFile{{"", "!keep.log\n*.log"}}, "keep.log", false, true},
+		{"no re-include under ignored folder", []ignoreFile{{"", "tmp/\n!tmp/keep.txt"}}, "tmp/keep.txt", false, true},
+		{"re-include with folder content pattern", []ignoreFile{{"", "tmp/*\n!tmp/keep.txt"}}, "tmp/keep.txt", false, false},
+		{"sub-folder rules are relative", []ignoreFile{{"src", "/gen"}}, "src/gen", true, true},
+		{"sub-folder rules stay inside", []ignoreFile{{"src", "*.o"}}, "lib/a.o", false, false},
+		{"sub-folder negates parent", []ignoreFile{{"", "*.log"}, {"src", "!important.log"}}, "src/important.log", false, false},
+		{"path is cleaned", []ignoreFile{{"", "/build"}}, "./build", true, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			il := newIgnoreList(t, test.files...)
+			if ignored := il.Match(test.path, test.isDir); ignored != test.ignored {
+				t.Errorf("Match(%q, %v) = %v, want %v", test.path, test.isDir, ignored, test.ignored)
+			}
+		})
+	}
+}
+
+func TestParseIgnoreRuleSkipsBlankLines(t *testing.T) {
+	for _, line := range []string{"", "   ", "\r", "# comment", "!", "/"} {
+		if _, ok, err := parseIgnoreRule("", line); ok || err != nil {
+			t.Errorf("parseIgnoreRule(%q) returned a rule or an error: %v", line, err)
+		}
+	}
+}
+
+func TestParseIgnoreRuleRejectsUnknownClasses(t *testing.T) {
+	for _, line := range []string{"[[:word:]]", "a[[:foo:]b]"} {
+		if _, _, err := parseIgnoreRule("", line); err == nil {
+			t.Errorf("parseIgnoreRule(%q) accepted an unknown character class", line)
+		}
+	}
+}
+
+func TestReadIgnoreListReportsInvalidPatterns(t *testing.T) {
+	inTempDir(t)
+	if err := os.WriteFile(IgnoreFilename, []byte("*.log\n[[:foo:]]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ReadIgnoreList()
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("ReadIgnoreList() = %v, want an error about line 2", err)
+	}
+}
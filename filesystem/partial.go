@@ -0,0 +1,121 @@
+package filesystem
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PartialTransfer records a transfer that has started but not completed yet,
+// so that the next run can tell whether the destination holds a prefix of
+// the very same source file.
+type PartialTransfer struct {
+	Source  string    `yaml:"source"`
+	Size    int64     `yaml:"size"`
+	ModTime time.Time `yaml:"modified"`
+	Started time.Time `yaml:"started"`
+	Upload  bool      `yaml:"upload"`
+}
+
+// partialTransfers holds the transfers of each target by destination, as the
+// same remote path may be written on several servers
+var partialTransfers struct {
+	sync.Mutex
+	targets map[string]map[string]PartialTransfer
+}
+
+func loadPartialTransfers(target string) map[string]PartialTransfer {
+	if transfers, ok := partialTransfers.targets[target]; ok {
+		return transfers
+	}
+	if partialTransfers.targets == nil {
+		partialTransfers.targets = map[string]map[string]PartialTransfer{}
+	}
+	transfers := map[string]PartialTransfer{}
+	content, err := os.ReadFile(stateFilename(target, "partial"))
+	if err == nil {
+		yaml.Unmarshal(content, &transfers)
+	}
+	partialTransfers.targets[target] = transfers
+	return transfers
+}
+
+func storePartialTransfers(target string) error {
+	filename := stateFilename(target, "partial")
+	transfers := partialTransfers.targets[target]
+	if len(transfers) == 0 {
+		err := os.Remove(filename)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	yamlData, err := yaml.Marshal(transfers)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, yamlData, 0644)
+}
+
+// ResumeOffset returns how many bytes of source were already written to
+// destination by an interrupted transfer, or 0 if it has to start over.
+// The destination must have been written after the transfer started and the
+// source must be unchanged since then.
+func ResumeOffset(target string, destination string, source FileData, destinationSize int64, destinationModTime time.Time) int64 {
+	partialTransfers.Lock()
+	defer partialTransfers.Unlock()
+
+	transfer, ok := loadPartialTransfers(target)[destination]
+	if !ok || transfer.Source != source.AbsolutePath || transfer.Size != source.Size || !transfer.ModTime.Equal(source.ModTime) {
+		return 0
+	}
+	// servers may only keep modification times to the minute
+	if destinationSize <= 0 || destinationSize >= source.Size || destinationModTime.Before(transfer.Started.Truncate(time.Minute)) {
+		return 0
+	}
+	return destinationSize
+}
+
+// StartTransfer records that destination is going to be written from source
+func StartTransfer(target string, destination string, source FileData, upload bool) error {
+	partialTransfers.Lock()
+	defer partialTransfers.Unlock()
+	transfers := loadPartialTransfers(target)
+
+	if transfer, ok := transfers[destination]; ok && transfer.Source == source.AbsolutePath &&
+		transfer.Size == source.Size && transfer.ModTime.Equal(source.ModTime) {
+		// resuming, the destination was written since the first start
+		return nil
+	}
+	transfers[destination] = PartialTransfer{source.AbsolutePath, source.Size, source.ModTime, time.Now(), upload}
+	return storePartialTransfers(target)
+}
+
+// FinishTransfer forgets about destination once it's completely written
+func FinishTransfer(target string, destination string) error {
+	partialTransfers.Lock()
+	defer partialTransfers.Unlock()
+	transfers := loadPartialTransfers(target)
+
+	if _, ok := transfers[destination]; !ok {
+		return nil
+	}
+	delete(transfers, destination)
+	return storePartialTransfers(target)
+}
+
+// PartialUploads returns the uploads to a target that were started but never finished, by destination
+func PartialUploads(target string) map[string]PartialTransfer {
+	partialTransfers.Lock()
+	defer partialTransfers.Unlock()
+
+	uploads := map[string]PartialTransfer{}
+	for destination, transfer := range loadPartialTransfers(target) {
+		if transfer.Upload {
+			uploads[destination] = transfer
+		}
+	}
+	return uploads
+}
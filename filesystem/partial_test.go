@@ -0,0 +1,82 @@
+package filesystem
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// inTempDir runs the rest of the test from an empty working copy
+func inTempDir(t *testing.T) {
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(previous) })
+}
+
+func TestResumeOffset(t *testing.T) {
+	inTempDir(t)
+	partialTransfers.targets = nil
+
+	source := FileData{AbsolutePath: "/local/a.txt", Size: 100, ModTime: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	if err := StartTransfer("default", "/remote/a.txt", source, true); err != nil {
+		t.Fatal(err)
+	}
+	started := partialTransfers.targets["default"]["/remote/a.txt"].Started
+	modified, resized, moved := source, source, source
+	modified.ModTime = modified.ModTime.Add(time.Second)
+	resized.Size = 101
+	moved.AbsolutePath = "/local/b.txt"
+
+	tests := []struct {
+		name        string
+		target      string
+		destination string
+		source      FileData
+		size        int64
+		modTime     time.Time
+		offset      int64
+	}{
+		{"prefix written since the start", "default", "/remote/a.txt", source, 40, started.Add(time.Second), 40},
+		{"server keeping minutes only", "default", "/remote/a.txt", source, 40, started.Truncate(time.Minute), 40},
+		{"written before the start", "default", "/remote/a.txt", source, 40, started.Add(-time.Hour), 0},
+		{"empty destination", "default", "/remote/a.txt", source, 0, started, 0},
+		{"complete destination", "default", "/remote/a.txt", source, 100, started, 0},
+		{"longer destination", "default", "/remote/a.txt", source, 120, started, 0},
+		{"source modified since", "default", "/remote/a.txt", modified, 40, started, 0},
+		{"source resized since", "default", "/remote/a.txt", resized, 40, started, 0},
+		{"other source", "default", "/remote/a.txt", moved, 40, started, 0},
+		{"unknown destination", "default", "/remote/b.txt", source, 40, started, 0},
+		{"other target", "mirror", "/remote/a.txt", source, 40, started, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offset := ResumeOffset(test.target, test.destination, test.source, test.size, test.modTime)
+			if offset != test.offset {
+				t.Errorf("ResumeOffset() = %d, want %d", offset, test.offset)
+			}
+		})
+	}
+
+	// the next run reads the transfers back from the working copy
+	partialTransfers.targets = nil
+	if offset := ResumeOffset("default", "/remote/a.txt", source, 40, started); offset != 40 {
+		t.Errorf("ResumeOffset() after reloading = %d, want 40", offset)
+	}
+	if _, ok := PartialUploads("default")["/remote/a.txt"]; !ok {
+		t.Error("PartialUploads() misses the interrupted upload")
+	}
+	if err := FinishTransfer("default", "/remote/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if offset := ResumeOffset("default", "/remote/a.txt", source, 40, started); offset != 0 {
+		t.Errorf("ResumeOffset() of a finished transfer = %d, want 0", offset)
+	}
+	if _, err := os.Stat(stateFilename("default", "partial")); !os.IsNotExist(err) {
+		t.Errorf("partial transfers kept without any transfer: %v", err)
+	}
+}
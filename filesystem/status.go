@@ -0,0 +1,47 @@
+package filesystem
+
+import (
+	"sort"
+)
+
+type ChangeType string
+
+const (
+	Added    ChangeType = "added"
+	Modified ChangeType = "modified"
+	Deleted  ChangeType = "deleted"
+)
+
+type Change struct {
+	Type ChangeType
+	File FileData
+}
+
+// PendingChanges lists what the next publish to a target would send, without
+// storing the snapshot. Files unknown to the previous snapshot are reported as added.
+func PendingChanges(target string) ([]Change, error) {
+	previousFilesystem, err := ReadFileList(target)
+	if err != nil {
+		previousFilesystem = Filesystem{}
+	}
+	fs, err := CreateFileList(target)
+	if err != nil {
+		return nil, err
+	}
+	filesList := fs.List(previousFilesystem)
+
+	changes := []Change{}
+	for _, fileData := range filesList {
+		changeType := Modified
+		if fileData.IsDeleted {
+			changeType = Deleted
+		} else if previousFile, ok := previousFilesystem[fileData.AbsolutePath]; !ok || previousFile.IsDeleted {
+			changeType = Added
+		}
+		changes = append(changes, Change{changeType, fileData})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].File.RelativePath < changes[j].File.RelativePath
+	})
+	return changes, nil
+}
@@ -2,82 +2,325 @@ package main
 
 import (
 	"fileTransfer/configuration"
+	"fileTransfer/credentials"
 	files "fileTransfer/filesystem"
+	"fileTransfer/output"
 	"fileTransfer/protocols"
+	"fileTransfer/protocols/transport"
 
 	"fileTransfer/terminal"
 	"flag"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 )
 
+// target is the -target flag of every command
+var target string
+
+// passwordFile is the -password-file flag of the commands connecting to the server
+var passwordFile string
+
 func main() {
 	commands := terminal.CommandGroup{}
 	commands.Add(terminal.NewCommand("init", "prepares local configuration to connect to server", Init))
+	commands.Add(terminal.NewCommand("status", "lists files that publish would send, exits with status 3 if any", Status))
 	commands.Add(terminal.NewCommand("publish", "uploads latest modified files to server", Publish))
 	commands.Add(terminal.NewCommand("clone", "downloads all server content to current working directory", Clone))
+	commands.Add(terminal.NewCommand("pull", "downloads files changed on server since last update", Pull))
+	commands.Add(terminal.NewCommand("sync", "uploads local changes and downloads server changes, reporting conflicts", Sync))
+	commands.Add(terminal.NewCommand("verify", "compares working copy with server without transferring, exits with status 3 on differences", Verify))
+	for name := range commands {
+		commands.GetFlagSet(name).Var(&output.Format{}, "output", "output format: text, or json for newline delimited events on stdout")
+		commands.GetFlagSet(name).StringVar(&target, "target", "", "name of the configured server to use, the default target if not given")
+	}
+	for _, name := range []string{"publish", "clone", "pull", "sync", "verify"} {
+		commands.GetFlagSet(name).StringVar(&passwordFile, "password-file", "",
+			"read the server password from the first line of a file, unless "+credentials.EnvironmentVariable+" is set")
+	}
 	commands.Parse()
 }
 
+// Init adds a target to the configuration, or replaces it. Without -target
+// the default target is replaced, or created on the first run.
 func Init(cmd *flag.FlagSet, args []string) {
 	hostname := cmd.String("host", "localhost", "server host")
 	port := cmd.Int("port", 22, "server port")
 	username := cmd.String("user", "test", "server username")
 	maxConnections := cmd.Int("max-connections", 3, "server number of max concurrent connections")
 	serverFolder := cmd.String("folder", ".", "folder on server")
-	protocol := cmd.String("protocol", "SFTP", "Protocols available: SFTP, FTP, FTPS-IMPLICIT, FTPS-EXPLICIT")
+	protocol := cmd.String("protocol", "SFTP", "Protocols available: "+strings.Join(transport.Protocols(), ", "))
+	pruneEmptyFolders := cmd.Bool("prune-empty-folders", false, "remove server folders left empty when publishing deletions")
+	isDefault := cmd.Bool("default", false, "make the target the default one")
 
 	cmd.Parse(args)
 	config := configuration.New()
+	if target != "" {
+		config.Target = target
+	} else if existing, err := configuration.Read(""); err == nil {
+		config.Target = existing.Target
+	}
 	config.Hostname = *hostname
 	config.Port = *port
 	config.Username = *username
 	config.MaxConnections = *maxConnections
 	config.ServerFolder = *serverFolder
 	config.Protocol = *protocol
-	err := config.Store()
+	config.PruneEmptyFolders = *pruneEmptyFolders
+	err := config.Store(*isDefault)
 	if err != nil {
 		log.Fatal(err)
 	}
-	fmt.Println("Configuration stored")
+	if !output.JSON() {
+		fmt.Println("Configuration stored")
+	}
 
-	_, err = files.CreateAndStoreFileList()
+	// nothing is published yet, so the first publish sends every file
+	err = files.Filesystem{}.Store(config.Target)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
-func Publish(cmd *flag.FlagSet, args []string) {
+// Status exits with this code when there are changes to publish, so that
+// scripts can tell it apart from errors
+const pendingChangesExitCode = 3
+
+func Status(cmd *flag.FlagSet, args []string) {
 	cmd.Parse(args)
-	password := terminal.InputPassword()
-	config, err := configuration.Read()
+	// only working copies prepared by init have a snapshot to compare with
+	config, err := configuration.Read(target)
 	if err != nil {
 		log.Fatal(err)
 	}
-	conn, err := protocols.Connect(*config, password)
+	changes, err := files.PendingChanges(config.Target)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer conn.Close()
-	err = protocols.PushChanges(conn, *config)
+	if output.JSON() {
+		for _, change := range changes {
+			output.Changed(string(change.Type), change.File.RelativePath)
+		}
+	} else if len(changes) == 0 {
+		fmt.Println("Nothing to publish")
+	} else {
+		fmt.Println("Changes to publish:")
+		for _, change := range changes {
+			fmt.Printf("\t%-10s %s\n", string(change.Type)+":", change.File.RelativePath)
+		}
+	}
+	if len(changes) > 0 {
+		os.Exit(pendingChangesExitCode)
+	}
+}
+
+const verifyUsage = "check the size and checksum of every transferred file"
+
+func Publish(cmd *flag.FlagSet, args []string) {
+	dryRun := cmd.Bool("dry-run", false, "print what would be transferred without changing anything")
+	verify := cmd.Bool("verify", false, verifyUsage)
+	allTargets := cmd.Bool("all-targets", false, "publish to every configured target at once")
+	bandwidthLimits := addBandwidthFlags(cmd)
+	failFast := addFailureFlags(cmd)
+	cmd.Parse(args)
+	if *allTargets && target != "" {
+		log.Fatal("--all-targets and --target cannot be used together")
+	}
+	configure := func(config *configuration.Configuration) {
+		config.DryRun = *dryRun
+		config.FailFast = failFast()
+		config.Verify = *verify
+		bandwidthLimits(config)
+	}
+	if *allTargets {
+		publishToAll(configure)
+		return
+	}
+	config, err := configuration.Read(target)
+	if err != nil {
+		log.Fatal(err)
+	}
+	configure(config)
+	runWithConnection(*config, func(conn transport.Transport) error {
+		return protocols.PushChanges(conn, *config)
+	})
+}
+
+// publishToAll connects to every configured target and publishes to those it
+// could connect to at once. The outcome of each target is logged, failing
+// ones making the command exit with an error.
+func publishToAll(configure func(config *configuration.Configuration)) {
+	targets, err := configuration.Targets()
 	if err != nil {
 		log.Fatal(err)
 	}
+	errs := map[string]error{}
+	configs := []configuration.Configuration{}
+	conns := []transport.Transport{}
+	for _, name := range targets {
+		config, err := configuration.Read(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		configure(config)
+		log.Printf("connecting to target %s (%s)\n", name, config.Hostname)
+		conn, err := protocols.Connect(*config, credentials.Password(*config, passwordFile))
+		if err != nil {
+			errs[name] = err
+			continue
+		}
+		configs = append(configs, *config)
+		conns = append(conns, conn)
+	}
+	for name, err := range protocols.PushChangesToAll(conns, configs) {
+		errs[name] = err
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+
+	failed := false
+	for _, name := range targets {
+		if errs[name] != nil {
+			log.Printf("target %s failed: %v\n", name, errs[name])
+			failed = true
+		} else {
+			log.Printf("target %s done\n", name)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
 }
 
 func Clone(cmd *flag.FlagSet, args []string) {
+	dryRun := cmd.Bool("dry-run", false, "print what would be transferred without changing anything")
+	verify := cmd.Bool("verify", false, verifyUsage)
+	bandwidthLimits := addBandwidthFlags(cmd)
+	failFast := addFailureFlags(cmd)
 	cmd.Parse(args)
-	password := terminal.InputPassword()
-	config, err := configuration.Read()
+	config, err := configuration.Read(target)
 	if err != nil {
 		log.Fatal(err)
 	}
-	conn, err := protocols.Connect(*config, password)
+	config.DryRun = *dryRun
+	config.FailFast = failFast()
+	config.Verify = *verify
+	bandwidthLimits(config)
+	runWithConnection(*config, func(conn transport.Transport) error {
+		return protocols.Clone(conn, *config)
+	})
+}
+
+func Pull(cmd *flag.FlagSet, args []string) {
+	deleteMissing := cmd.Bool("delete", false, "delete local files that no longer exist on server")
+	verify := cmd.Bool("verify", false, verifyUsage)
+	bandwidthLimits := addBandwidthFlags(cmd)
+	failFast := addFailureFlags(cmd)
+	cmd.Parse(args)
+	config, err := configuration.Read(target)
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.FailFast = failFast()
+	config.Verify = *verify
+	bandwidthLimits(config)
+	runWithConnection(*config, func(conn transport.Transport) error {
+		return protocols.Pull(conn, *config, *deleteMissing)
+	})
+}
+
+func Sync(cmd *flag.FlagSet, args []string) {
+	verify := cmd.Bool("verify", false, verifyUsage)
+	bandwidthLimits := addBandwidthFlags(cmd)
+	failFast := addFailureFlags(cmd)
+	cmd.Parse(args)
+	config, err := configuration.Read(target)
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.FailFast = failFast()
+	config.Verify = *verify
+	bandwidthLimits(config)
+	runWithConnection(*config, func(conn transport.Transport) error {
+		return protocols.Sync(conn, *config)
+	})
+}
+
+// Verify exits with this code when the server doesn't match the working copy
+const driftExitCode = 3
+
+func Verify(cmd *flag.FlagSet, args []string) {
+	compareHashes := cmd.Bool("hash", false, "also compare the sha256 of files, which may download them")
+	cmd.Parse(args)
+	config, err := configuration.Read(target)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var drifts []protocols.Drift
+	runWithConnection(*config, func(conn transport.Transport) (err error) {
+		drifts, err = protocols.Verify(conn, *config, *compareHashes)
+		return err
+	})
+	if output.JSON() {
+		for _, drift := range drifts {
+			output.Drifted(string(drift.Type), drift.Path, drift.Reason)
+		}
+	} else if len(drifts) == 0 {
+		fmt.Println("Server matches working copy")
+	} else {
+		fmt.Println("Server differs from working copy:")
+		for _, drift := range drifts {
+			fmt.Printf("\t%-10s %s (%s)\n", string(drift.Type)+":", drift.Path, drift.Reason)
+		}
+	}
+	if len(drifts) > 0 {
+		os.Exit(driftExitCode)
+	}
+}
+
+// addFailureFlags registers --fail-fast and --keep-going, keeping going being
+// the default. The returned function must be called once flags are parsed.
+func addFailureFlags(cmd *flag.FlagSet) func() bool {
+	failFast := cmd.Bool("fail-fast", false, "stop at the first file that fails to transfer")
+	keepGoing := cmd.Bool("keep-going", false, "transfer all the files even when some fail (default)")
+	return func() bool {
+		if *failFast && *keepGoing {
+			log.Fatal("--fail-fast and --keep-going cannot be used together")
+		}
+		return *failFast
+	}
+}
+
+// addBandwidthFlags registers the limits overriding the configured ones. The
+// returned function applies the flags given once they are parsed.
+func addBandwidthFlags(cmd *flag.FlagSet) func(config *configuration.Configuration) {
+	bandwidthLimit := cmd.Int("bwlimit", 0, "limit the transfer rate of all the workers in KiB/s, 0 for unlimited")
+	uploadLimit := cmd.Int("upload-bwlimit", 0, "limit the upload rate in KiB/s, instead of -bwlimit")
+	downloadLimit := cmd.Int("download-bwlimit", 0, "limit the download rate in KiB/s, instead of -bwlimit")
+	return func(config *configuration.Configuration) {
+		cmd.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "bwlimit":
+				config.BandwidthLimit = *bandwidthLimit
+			case "upload-bwlimit":
+				config.UploadLimit = *uploadLimit
+			case "download-bwlimit":
+				config.DownloadLimit = *downloadLimit
+			}
+		})
+	}
+}
+
+func runWithConnection(config configuration.Configuration, run func(conn transport.Transport) error) {
+	conn, err := protocols.Connect(config, credentials.Password(config, passwordFile))
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer conn.Close()
-	err = protocols.Clone(conn, *config)
+	err = run(conn)
+	// log.Fatal doesn't run deferred calls, so the connection is closed first
+	conn.Close()
 	if err != nil {
 		log.Fatal(err)
 	}
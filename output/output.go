@@ -0,0 +1,203 @@
+// Package output emits the newline delimited JSON events of the json output
+// format. Field names are read by other tools, so they must not change.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	mutex   sync.Mutex
+	enabled bool
+	encoder = json.NewEncoder(os.Stdout)
+)
+
+// Format is the value of the -output flag, setting it to json enables the events
+type Format struct{}
+
+func (Format) String() string {
+	if JSON() {
+		return "json"
+	}
+	return "text"
+}
+
+func (Format) Set(value string) error {
+	switch value {
+	case "json":
+		mutex.Lock()
+		enabled = true
+		mutex.Unlock()
+	case "text":
+	default:
+		return fmt.Errorf("unknown output format %q, expected text or json", value)
+	}
+	return nil
+}
+
+// JSON tells whether commands write events instead of text
+func JSON() bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return enabled
+}
+
+// Event holds the fields common to all events, it's embedded by each of them
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+}
+
+func newEvent(eventType string) Event {
+	return Event{eventType, time.Now()}
+}
+
+// emit writes an event on its own line, events being written by several workers at once
+func emit(event interface{}) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if enabled {
+		encoder.Encode(event)
+	}
+}
+
+// FileStarted begins the transfer of a file. Like the other file events, it
+// only names the target when publishing to several at once.
+type FileStarted struct {
+	Event
+	Target      string `json:"target,omitempty"`
+	Direction   string `json:"direction"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Size        int64  `json:"size"`
+	Offset      int64  `json:"offset"`
+}
+
+func Started(target string, direction string, source string, destination string, size int64, offset int64) {
+	emit(FileStarted{newEvent("file-started"), target, direction, source, destination, size, offset})
+}
+
+type FileProgress struct {
+	Event
+	Target    string `json:"target,omitempty"`
+	Direction string `json:"direction"`
+	Source    string `json:"source"`
+	Bytes     int64  `json:"bytes"`
+	Size      int64  `json:"size"`
+}
+
+func Progress(target string, direction string, source string, bytes int64, size int64) {
+	emit(FileProgress{newEvent("file-progress"), target, direction, source, bytes, size})
+}
+
+type FileCompleted struct {
+	Event
+	Target      string `json:"target,omitempty"`
+	Direction   string `json:"direction"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Bytes       int64  `json:"bytes"`
+}
+
+func Completed(target string, direction string, source string, destination string, bytes int64) {
+	emit(FileCompleted{newEvent("file-completed"), target, direction, source, destination, bytes})
+}
+
+type FileDeleted struct {
+	Event
+	Target string `json:"target,omitempty"`
+	Path   string `json:"path"`
+}
+
+func Deleted(target string, path string) {
+	emit(FileDeleted{newEvent("file-deleted"), target, path})
+}
+
+type FileSkipped struct {
+	Event
+	Target string `json:"target,omitempty"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+func Skipped(target string, path string, reason string) {
+	emit(FileSkipped{newEvent("file-skipped"), target, path, reason})
+}
+
+type FileError struct {
+	Event
+	Target  string `json:"target,omitempty"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func Error(target string, path string, err error) {
+	emit(FileError{newEvent("error"), target, path, err.Error()})
+}
+
+type Conflict struct {
+	Event
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func Conflicted(path string, message string) {
+	emit(Conflict{newEvent("conflict"), path, message})
+}
+
+type RunSummary struct {
+	Event
+	Target      string `json:"target,omitempty"`
+	Direction   string `json:"direction"`
+	Transferred int    `json:"transferred"`
+	Bytes       int64  `json:"bytes"`
+	Deleted     int    `json:"deleted"`
+	Failed      int    `json:"failed"`
+	Skipped     int    `json:"skipped"`
+	DurationMs  int64  `json:"duration_ms"`
+}
+
+// Summary ends a run in one direction, target being only given when publishing to several at once
+func Summary(target string, direction string, transferred int, bytes int64, deleted int, failed int, skipped int, duration time.Duration) {
+	emit(RunSummary{newEvent("summary"), target, direction, transferred, bytes, deleted, failed, skipped, duration.Milliseconds()})
+}
+
+// Change is a file the next publish would send, listed by status
+type Change struct {
+	Event
+	Change string `json:"change"`
+	Path   string `json:"path"`
+}
+
+func Changed(change string, path string) {
+	emit(Change{newEvent("change"), change, path})
+}
+
+// Drift is a file that differs between the working copy and the server, listed by verify
+type Drift struct {
+	Event
+	Drift  string `json:"drift"`
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+func Drifted(drift string, path string, reason string) {
+	emit(Drift{newEvent("drift"), drift, path, reason})
+}
+
+// Planned is an action a dry run would take: create-folder, upload, download or delete
+type Planned struct {
+	Event
+	Action      string `json:"action"`
+	Source      string `json:"source,omitempty"`
+	Destination string `json:"destination"`
+	Size        int64  `json:"size,omitempty"`
+}
+
+func Plan(action string, source string, destination string, size int64) {
+	emit(Planned{newEvent("planned"), action, source, destination, size})
+}
@@ -0,0 +1,109 @@
+package protocols
+
+import (
+	clientConfig "fileTransfer/configuration"
+	files "fileTransfer/filesystem"
+	"fileTransfer/protocols/transport"
+	"fmt"
+	"os"
+	"sort"
+)
+
+type DriftType string
+
+const (
+	Missing   DriftType = "missing"
+	Extra     DriftType = "extra"
+	Differing DriftType = "differs"
+)
+
+// Drift is a file that isn't the same in the working copy and on the server
+type Drift struct {
+	Type   DriftType
+	Path   string
+	Reason string
+}
+
+// Verify compares the working copy with the server without transferring
+// anything. Files missing on the server or only found there are reported, and
+// files on both sides differ when their sizes differ, when one side was
+// modified since they were last transferred or, with compareHashes, when
+// their sha256 differ.
+func Verify(conn transport.Transport, config clientConfig.Configuration, compareHashes bool) ([]Drift, error) {
+	published, err := files.ReadFileList(config.Target)
+	if err != nil {
+		published = files.Filesystem{}
+	}
+	localFiles, err := files.CreateFileList(config.Target)
+	if err != nil {
+		return nil, err
+	}
+	remoteList, err := listRemoteFiles(conn, config)
+	if err != nil {
+		return nil, err
+	}
+
+	currentDirectory, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get current working directory: %v", err)
+	}
+	remoteFiles := files.Filesystem{}
+	for _, remoteFile := range remoteList {
+		remoteFiles[localPath(currentDirectory, remoteFile)] = remoteFile
+	}
+
+	drifts := []Drift{}
+	for filename, localFile := range localFiles {
+		if localFile.IsDeleted || files.IsConflictCopy(filename) {
+			continue
+		}
+		remoteFile, ok := remoteFiles[filename]
+		if !ok {
+			drifts = append(drifts, Drift{Missing, localFile.RelativePath, "not on server"})
+			continue
+		}
+		reason, err := difference(conn, localFile, remoteFile, published, compareHashes)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			drifts = append(drifts, Drift{Differing, localFile.RelativePath, reason})
+		}
+	}
+	for filename, remoteFile := range remoteFiles {
+		if localFile, ok := localFiles[filename]; !ok || localFile.IsDeleted {
+			drifts = append(drifts, Drift{Extra, remoteFile.RelativePath, "only on server"})
+		}
+	}
+	sort.Slice(drifts, func(i, j int) bool {
+		return drifts[i].Path < drifts[j].Path
+	})
+	return drifts, nil
+}
+
+// difference explains why a file differs between both sides, or returns an
+// empty string when it doesn't
+func difference(conn transport.Transport, localFile files.FileData, remoteFile files.FileData, published files.Filesystem, compareHashes bool) (string, error) {
+	if localFile.Size != remoteFile.Size {
+		return fmt.Sprintf("%d bytes locally, %d bytes on server", localFile.Size, remoteFile.Size), nil
+	}
+	publishedFile, ok := published[localFile.AbsolutePath]
+	switch {
+	case !ok && !localFile.ModTime.Equal(remoteFile.ModTime):
+		return "never transferred, modification times differ", nil
+	case ok && !remoteFile.ModTime.Equal(publishedFile.PublishedModTime()):
+		return "modified on server since last transfer", nil
+	case ok && localFile.Hash != publishedFile.Hash:
+		return "modified locally since last transfer", nil
+	}
+	if compareHashes {
+		hash, err := remoteHash(conn, remoteFile.AbsolutePath)
+		if err != nil {
+			return "", fmt.Errorf("cannot hash remote file (%s): %w", remoteFile.AbsolutePath, err)
+		}
+		if hash != localFile.Hash {
+			return "checksums differ", nil
+		}
+	}
+	return "", nil
+}
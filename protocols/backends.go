@@ -0,0 +1,7 @@
+package protocols
+
+// Backends register themselves with the transport package when imported.
+import (
+	_ "fileTransfer/protocols/ftp"
+	_ "fileTransfer/protocols/sftp"
+)
@@ -0,0 +1,197 @@
+package ftp
+
+import (
+	"errors"
+	clientConfig "fileTransfer/configuration"
+	files "fileTransfer/filesystem"
+	"fileTransfer/protocols/transport"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/secsy/goftp"
+)
+
+func init() {
+	for _, protocol := range []string{"FTP", "FTPS-IMPLICIT", "FTPS-EXPLICIT"} {
+		transport.Register(protocol, Dial)
+	}
+}
+
+// Client implements transport.Transport on top of a goftp client, which
+// already keeps its own pool of control connections.
+type Client struct {
+	mutex        sync.RWMutex
+	reconnecting sync.Mutex
+	conn         *goftp.Client
+	config       clientConfig.Configuration
+	password     string
+	noHash       int32
+}
+
+// upload streams whatever is written to it into a STOR command
+type upload struct {
+	*io.PipeWriter
+	done chan error
+}
+
+func (u *upload) Close() error {
+	u.PipeWriter.Close()
+	return <-u.done
+}
+
+// Dial resolves the password right away, FTP servers always asking for one
+func Dial(ftpConfig clientConfig.Configuration, password transport.Password) (transport.Transport, error) {
+	secret, err := password()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := connect(ftpConfig, secret)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, config: ftpConfig, password: secret}, nil
+}
+
+func (c *Client) client() *goftp.Client {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.conn
+}
+
+// Retryable accepts the transient replies that may clear up on their own: 421
+// (service closing), 425 (can't open data connection), 426 (transfer aborted),
+// 450 (file busy) and 451 (local error), along with network timeouts
+func (c *Client) Retryable(err error) bool {
+	var ftpErr goftp.Error
+	if !errors.As(err, &ftpErr) {
+		return false
+	}
+	switch ftpErr.Code() {
+	case 421, 425, 426, 450, 451:
+		return true
+	case 0:
+		return ftpErr.Temporary()
+	}
+	return false
+}
+
+// Reconnect checks the server with a NOOP first, so that the workers failing
+// together replace the client only once. As with SFTP, the probe doesn't hold
+// the client lock, workers using the client meanwhile rather than waiting.
+func (c *Client) Reconnect() error {
+	c.reconnecting.Lock()
+	defer c.reconnecting.Unlock()
+	probed := c.client()
+	if raw, err := probed.OpenRawConn(); err == nil {
+		err = sendExpected(raw, 200, "NOOP")
+		raw.Close()
+		if err == nil {
+			return nil
+		}
+	}
+	conn, err := connect(c.config, c.password)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.conn != probed {
+		conn.Close()
+		return nil
+	}
+	c.conn.Close()
+	c.conn = conn
+	return nil
+}
+
+func (c *Client) List(root string) ([]files.FileData, error) {
+	return listFiles(c.client(), root)
+}
+
+func (c *Client) Stat(path string) (os.FileInfo, error) {
+	return c.client().Stat(path)
+}
+
+func (c *Client) Open(path string) (io.ReadCloser, error) {
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(c.client().Retrieve(path, writer))
+	}()
+	return reader, nil
+}
+
+func (c *Client) Create(path string) (io.WriteCloser, error) {
+	reader, writer := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := c.client().Store(path, reader)
+		reader.CloseWithError(err)
+		done <- err
+	}()
+	return &upload{writer, done}, nil
+}
+
+func (c *Client) MkdirAll(remotePath string) error {
+	folders := strings.Split(path.Clean(remotePath), "/")
+	incrementalPath := "/"
+	for _, folder := range folders {
+		if folder == "" {
+			continue
+		}
+		incrementalPath = path.Join(incrementalPath, folder)
+		// NOTE: ingnoring errors as I they are rarely helpful
+		c.client().Mkdir(incrementalPath)
+	}
+	return nil
+}
+
+// Remove reports a 550 reply, which servers send for missing files, as
+// os.ErrNotExist
+func (c *Client) Remove(path string) error {
+	err := c.client().Delete(path)
+	var ftpErr goftp.Error
+	if errors.As(err, &ftpErr) && ftpErr.Code() == 550 {
+		return &os.PathError{Op: "remove", Path: path, Err: os.ErrNotExist}
+	}
+	return err
+}
+
+func (c *Client) RemoveDirectory(path string) error {
+	return c.client().Rmdir(path)
+}
+
+// Rename replaces newPath if it already exists. Servers refusing to rename
+// onto an existing file get newPath removed first, so the replacement isn't
+// atomic on them.
+func (c *Client) Rename(oldPath, newPath string) error {
+	client := c.client()
+	err := client.Rename(oldPath, newPath)
+	if err == nil || !targetExists(client, err, oldPath, newPath) {
+		return err
+	}
+	if err = client.Delete(newPath); err != nil {
+		return err
+	}
+	return client.Rename(oldPath, newPath)
+}
+
+// targetExists tells if a rename failed only because newPath is an existing
+// file, which servers report with a 550 or 553 reply
+func targetExists(client *goftp.Client, err error, oldPath, newPath string) bool {
+	var ftpErr goftp.Error
+	if !errors.As(err, &ftpErr) || (ftpErr.Code() != 550 && ftpErr.Code() != 553) {
+		return false
+	}
+	if _, err := client.Stat(oldPath); err != nil {
+		return false
+	}
+	info, err := client.Stat(newPath)
+	return err == nil && !info.IsDir()
+}
+
+func (c *Client) Close() error {
+	return c.client().Close()
+}
@@ -12,7 +12,7 @@ import (
 	"github.com/secsy/goftp"
 )
 
-func Connect(ftpConfig clientConfig.Configuration, password string) (*goftp.Client, error) {
+func connect(ftpConfig clientConfig.Configuration, password string) (*goftp.Client, error) {
 	tlsConfig := tls.Config{
 		InsecureSkipVerify:     true,
 		ServerName:             ftpConfig.Hostname,
@@ -36,7 +36,7 @@ func Connect(ftpConfig clientConfig.Configuration, password string) (*goftp.Clie
 	case "FTPS-IMPLICIT":
 		config.TLSMode = goftp.TLSImplicit
 		config.TLSConfig = &tlsConfig
-	case "FTP-EXPLICIT":
+	case "FTPS-EXPLICIT":
 		config.TLSMode = goftp.TLSExplicit
 		config.TLSConfig = &tlsConfig
 	case "FTP":
@@ -46,7 +46,7 @@ func Connect(ftpConfig clientConfig.Configuration, password string) (*goftp.Clie
 	hostnameAndPort := fmt.Sprintf("%s:%d", ftpConfig.Hostname, ftpConfig.Port)
 	client, err := goftp.DialConfig(config, hostnameAndPort)
 	if err != nil {
-		return nil, fmt.Errorf("unable to connect: %v", err)
+		return nil, fmt.Errorf("unable to connect: %w", err)
 	}
 	return client, nil
 }
@@ -0,0 +1,49 @@
+package ftp
+
+import (
+	"fileTransfer/protocols/transport"
+	"strings"
+	"sync/atomic"
+)
+
+func sha256Field(fields []string) (string, bool) {
+	for _, field := range fields {
+		if len(field) == 64 {
+			return strings.ToLower(field), true
+		}
+	}
+	return "", false
+}
+
+// Hash tries the HASH command of the FTP HASH extension draft, then the
+// XSHA256 command some servers implement instead. A server knowing none of
+// them is remembered for the next files.
+func (c *Client) Hash(path string) (string, error) {
+	if atomic.LoadInt32(&c.noHash) == 1 {
+		return "", transport.ErrHashUnsupported
+	}
+	raw, err := c.client().OpenRawConn()
+	if err != nil {
+		return "", err
+	}
+	defer raw.Close()
+	if err = sendExpected(raw, 200, "OPTS HASH SHA-256"); err == nil {
+		code, message, err := raw.SendCommand("HASH %s", path)
+		if err != nil {
+			return "", err
+		}
+		// 213 SHA-256 <range> <hash> <filename>
+		if hash, ok := sha256Field(strings.Fields(message)); ok && code == 213 {
+			return hash, nil
+		}
+	}
+	code, message, err := raw.SendCommand("XSHA256 %s", path)
+	if err != nil {
+		return "", err
+	}
+	if hash, ok := sha256Field(strings.Fields(message)); ok && code/100 == 2 {
+		return hash, nil
+	}
+	atomic.StoreInt32(&c.noHash, 1)
+	return "", transport.ErrHashUnsupported
+}
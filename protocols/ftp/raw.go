@@ -0,0 +1,104 @@
+package ftp
+
+import (
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/secsy/goftp"
+)
+
+// rawTransfer is a data connection opened on a dedicated control connection,
+// used for the commands goftp doesn't expose (REST before RETR, APPE)
+type rawTransfer struct {
+	net.Conn
+	raw goftp.RawConn
+}
+
+// replyError is an unexpected reply to a raw command, it implements goftp.Error
+// so that it's classified like the errors of goftp itself
+type replyError struct {
+	code    int
+	message string
+}
+
+func (e replyError) Error() string {
+	return fmt.Sprintf("unexpected response: %d-%s", e.code, e.message)
+}
+
+func (e replyError) Temporary() bool {
+	return e.code >= 400 && e.code < 500
+}
+
+func (e replyError) Code() int {
+	return e.code
+}
+
+func (e replyError) Message() string {
+	return e.message
+}
+
+func (t *rawTransfer) Close() error {
+	defer t.raw.Close()
+	t.Conn.Close()
+	code, msg, err := t.raw.ReadResponse()
+	if err != nil {
+		return err
+	}
+	if code < 200 || code >= 300 {
+		return replyError{code, msg}
+	}
+	return nil
+}
+
+func sendExpected(raw goftp.RawConn, expected int, format string, args ...interface{}) error {
+	code, msg, err := raw.SendCommand(format, args...)
+	if err != nil {
+		return err
+	}
+	// expected is either a full reply code or its first digit
+	if code != expected && code/100 != expected {
+		return replyError{code, msg}
+	}
+	return nil
+}
+
+func (c *Client) openRawTransfer(offset int64, format string, args ...interface{}) (*rawTransfer, error) {
+	raw, err := c.client().OpenRawConn()
+	if err != nil {
+		return nil, err
+	}
+	if err = sendExpected(raw, 200, "TYPE I"); err != nil {
+		raw.Close()
+		return nil, err
+	}
+	if offset > 0 {
+		if err = sendExpected(raw, 350, "REST %d", offset); err != nil {
+			raw.Close()
+			return nil, err
+		}
+	}
+	getDataConn, err := raw.PrepareDataConn()
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	if err = sendExpected(raw, 1, format, args...); err != nil {
+		raw.Close()
+		return nil, err
+	}
+	dataConn, err := getDataConn()
+	if err != nil {
+		raw.Close()
+		return nil, err
+	}
+	return &rawTransfer{dataConn, raw}, nil
+}
+
+func (c *Client) OpenAt(path string, offset int64) (io.ReadCloser, error) {
+	return c.openRawTransfer(offset, "RETR %s", path)
+}
+
+func (c *Client) Append(path string) (io.WriteCloser, error) {
+	return c.openRawTransfer(0, "APPE %s", path)
+}
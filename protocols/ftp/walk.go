@@ -0,0 +1,109 @@
+package ftp
+
+import (
+	"os"
+
+	"path/filepath"
+
+	"github.com/secsy/goftp"
+
+	files "fileTransfer/filesystem"
+	"fileTransfer/protocols/transport"
+
+	"path"
+
+	"sync"
+
+	"strings"
+)
+
+func listFiles(client *goftp.Client, rootFolder string) ([]files.FileData, error) {
+	list := []files.FileData{}
+	var mutex sync.Mutex
+
+	// It seems that forward slashes are also used on Windows FTP servers BTW
+	if !strings.HasPrefix(rootFolder, "/") {
+		rootFolder = "/" + rootFolder
+	}
+
+	err := walk(client, rootFolder, func(fullPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			// no permissions is okay, keep walking
+			if ftpErr, ok := err.(goftp.Error); ok && ftpErr.Code() == 550 {
+				return nil
+			}
+			return err
+		}
+
+		if !info.IsDir() {
+			filedata := files.FileData{
+				AbsolutePath: fullPath,
+				RelativePath: transport.Rel(rootFolder, fullPath),
+				Size:         info.Size(),
+				ModTime:      info.ModTime(),
+				IsDeleted:    false,
+			}
+			// walkFn is called from several goroutines at once
+			mutex.Lock()
+			list = append(list, filedata)
+			mutex.Unlock()
+		}
+
+		return nil
+	})
+	return list, err
+}
+
+// walk lists every folder in its own goroutine, goftp limiting how many run at
+// once to its connection pool. The first error stops the walk and is returned.
+func walk(client *goftp.Client, root string, walkFn filepath.WalkFunc) error {
+	var wg sync.WaitGroup
+	var once sync.Once
+	var ret error
+	stopped := make(chan struct{})
+	fail := func(err error) {
+		once.Do(func() {
+			ret = err
+			close(stopped)
+		})
+	}
+
+	var visit func(dir string)
+	visit = func(dir string) {
+		defer wg.Done()
+		select {
+		case <-stopped:
+			return
+		default:
+		}
+
+		files, err := client.ReadDir(dir)
+		if err != nil {
+			if err = walkFn(dir, nil, err); err != nil && err != filepath.SkipDir {
+				fail(err)
+				return
+			}
+		}
+
+		for _, file := range files {
+			fullPath := path.Join(dir, file.Name())
+			if err = walkFn(fullPath, file, nil); err != nil {
+				if file.IsDir() && err == filepath.SkipDir {
+					continue
+				}
+				fail(err)
+				return
+			}
+
+			if file.IsDir() {
+				wg.Add(1)
+				go visit(fullPath)
+			}
+		}
+	}
+
+	wg.Add(1)
+	go visit(root)
+	wg.Wait()
+	return ret
+}
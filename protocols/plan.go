@@ -0,0 +1,112 @@
+package protocols
+
+import (
+	clientConfig "fileTransfer/configuration"
+	files "fileTransfer/filesystem"
+	"fileTransfer/output"
+	"fileTransfer/protocols/transport"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// plan describes what a run would do, it's printed instead of being executed in dry-run mode
+type plan struct {
+	folders   []string
+	transfers []plannedTransfer
+	deletions []string
+	bytes     int64
+}
+
+type plannedTransfer struct {
+	source      string
+	destination string
+	size        int64
+}
+
+func (p *plan) transfer(source string, destination string, size int64) {
+	p.transfers = append(p.transfers, plannedTransfer{source, destination, size})
+	p.bytes += size
+}
+
+func (p *plan) Print(direction string) {
+	sort.Strings(p.folders)
+	sort.Slice(p.transfers, func(i, j int) bool { return p.transfers[i].source < p.transfers[j].source })
+	sort.Strings(p.deletions)
+	if output.JSON() {
+		for _, folder := range p.folders {
+			output.Plan("create-folder", "", folder, 0)
+		}
+		for _, transfer := range p.transfers {
+			output.Plan(direction, transfer.source, transfer.destination, transfer.size)
+		}
+		for _, deletion := range p.deletions {
+			output.Plan("delete", "", deletion, 0)
+		}
+		return
+	}
+	for _, folder := range p.folders {
+		fmt.Printf("create folder: %s\n", folder)
+	}
+	for _, transfer := range p.transfers {
+		fmt.Printf("%s: %s ---> %s [%d bytes]\n", direction, transfer.source, transfer.destination, transfer.size)
+	}
+	for _, deletion := range p.deletions {
+		fmt.Printf("delete: %s\n", deletion)
+	}
+	fmt.Printf("Dry run: %d file(s) to %s (%d bytes), %d file(s) to delete, %d folder(s) to create\n",
+		len(p.transfers), direction, p.bytes, len(p.deletions), len(p.folders))
+}
+
+// missingFolders returns the folders (and their parents) that don't exist yet according to exists
+func missingFolders(folders []string, exists func(folder string) bool) []string {
+	checked := map[string]bool{}
+	missing := []string{}
+	for _, folder := range folders {
+		for !checked[folder] && folder != "/" && folder != "." && folder != "" {
+			checked[folder] = true
+			if exists(folder) {
+				break
+			}
+			missing = append(missing, folder)
+			folder = path.Dir(folder)
+		}
+	}
+	return missing
+}
+
+func planUploads(conn transport.Transport, config clientConfig.Configuration, filesList []files.FileData) *plan {
+	p := &plan{}
+	folders := []string{}
+	for _, localFile := range filesList {
+		destinationFilename := remotePath(config, localFile)
+		if localFile.IsDeleted {
+			p.deletions = append(p.deletions, destinationFilename)
+			continue
+		}
+		p.transfer(localFile.AbsolutePath, destinationFilename, localFile.Size)
+		folders = append(folders, path.Dir(destinationFilename))
+	}
+	p.folders = missingFolders(folders, func(folder string) bool {
+		_, err := conn.Stat(folder)
+		return err == nil
+	})
+	return p
+}
+
+func planDownloads(currentDirectory string, remoteFiles []files.FileData) *plan {
+	p := &plan{}
+	folders := []string{}
+	for _, remoteFile := range remoteFiles {
+		clientPath := localPath(currentDirectory, remoteFile)
+		p.transfer(remoteFile.AbsolutePath, clientPath, remoteFile.Size)
+		folders = append(folders, filepath.ToSlash(filepath.Dir(clientPath)))
+	}
+	p.folders = missingFolders(folders, func(folder string) bool {
+		_, err := os.Stat(filepath.FromSlash(folder))
+		return err == nil
+	})
+	return p
+}
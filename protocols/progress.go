@@ -0,0 +1,216 @@
+package protocols
+
+import (
+	"fileTransfer/output"
+	"fileTransfer/terminal"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	redrawInterval   = 200 * time.Millisecond
+	plainLogInterval = 10 * time.Second
+	eventInterval    = time.Second
+)
+
+// fileProgress is a file being transferred, name being shown and source being
+// the path of its events
+type fileProgress struct {
+	name   string
+	source string
+	size   int64
+	copied int64
+}
+
+// progress tracks the bytes transferred by the workers of a run. On a terminal
+// the files in progress and the totals are redrawn in place below the log
+// lines, otherwise the totals are logged periodically. With JSON output a
+// progress event is emitted for every file in progress instead. Runs
+// publishing to several targets at once share the terminal, so they only log.
+type progress struct {
+	mutex         sync.Mutex
+	target        string
+	direction     string
+	totalFiles    int
+	totalBytes    int64
+	finishedFiles int
+	finishedBytes int64
+	current       map[*fileProgress]bool
+	started       time.Time
+	interactive   bool
+	drawnLines    int
+	stop          chan struct{}
+	stopped       chan struct{}
+}
+
+func newProgress(direction string, target string, totalFiles int, totalBytes int64) *progress {
+	return &progress{target: target, direction: direction, totalFiles: totalFiles, totalBytes: totalBytes,
+		current: map[*fileProgress]bool{}, interactive: terminal.IsTerminal(os.Stdout) && !output.JSON() && target == ""}
+}
+
+// Start renders the progress until Stop is called
+func (p *progress) Start() {
+	p.started = time.Now()
+	p.stop = make(chan struct{})
+	p.stopped = make(chan struct{})
+	interval := plainLogInterval
+	if output.JSON() {
+		interval = eventInterval
+	} else if p.interactive {
+		interval = redrawInterval
+		// log lines are written above the progress, which is drawn again after them
+		log.SetOutput(p)
+	}
+	go func() {
+		defer close(p.stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.render()
+			}
+		}
+	}()
+}
+
+func (p *progress) Stop() {
+	close(p.stop)
+	<-p.stopped
+	if p.interactive {
+		p.mutex.Lock()
+		p.erase()
+		p.mutex.Unlock()
+		log.SetOutput(os.Stderr)
+	}
+}
+
+// Write lets the progress be the output of the logger
+func (p *progress) Write(line []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.erase()
+	n, err := os.Stderr.Write(line)
+	p.draw()
+	return n, err
+}
+
+// track registers a file being transferred from offset, which is its first byte to copy
+func (p *progress) track(name string, source string, size int64, offset int64) *fileProgress {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	file := &fileProgress{name: name, source: source, size: size, copied: offset}
+	p.current[file] = true
+	return file
+}
+
+// finish unregisters a file, counting it in the totals only if it was transferred
+func (p *progress) finish(file *fileProgress, transferred bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.current, file)
+	if transferred {
+		p.finishedFiles++
+		p.finishedBytes += file.size
+	}
+}
+
+func (p *progress) add(file *fileProgress, n int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	file.copied += int64(n)
+}
+
+func (p *progress) render() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if output.JSON() {
+		for file := range p.current {
+			output.Progress(p.target, p.direction, file.source, file.copied, file.size)
+		}
+	} else if p.interactive {
+		p.erase()
+		p.draw()
+	} else {
+		log.Println(p.totals())
+	}
+}
+
+// totals describes the whole run, it must be called with the mutex locked
+func (p *progress) totals() string {
+	bytes := p.finishedBytes
+	for file := range p.current {
+		bytes += file.copied
+	}
+	elapsed := time.Since(p.started)
+	rate := float64(bytes) / elapsed.Seconds()
+	eta := "unknown"
+	if rate > 0 {
+		eta = time.Duration(float64(p.totalBytes-bytes) / rate * float64(time.Second)).Round(time.Second).String()
+	}
+	prefix := ""
+	if p.target != "" {
+		prefix = p.target + ": "
+	}
+	return fmt.Sprintf("%s%sing %d/%d file(s), %s/%s, %s/s, ETA %s", prefix, p.direction, p.finishedFiles, p.totalFiles,
+		formatBytes(bytes), formatBytes(p.totalBytes), formatBytes(int64(rate)), eta)
+}
+
+// draw writes the files in progress and the totals, it must be called with the mutex locked
+func (p *progress) draw() {
+	lines := []string{}
+	for file := range p.current {
+		percent := 100
+		if file.size > 0 {
+			percent = int(file.copied * 100 / file.size)
+		}
+		lines = append(lines, fmt.Sprintf("  %3d%% %s (%s/%s)", percent, file.name, formatBytes(file.copied), formatBytes(file.size)))
+	}
+	sort.Strings(lines)
+	lines = append(lines, p.totals())
+	fmt.Print(strings.Join(lines, "\n") + "\n")
+	p.drawnLines = len(lines)
+}
+
+// erase clears the lines drawn last, it must be called with the mutex locked
+func (p *progress) erase() {
+	for ; p.drawnLines > 0; p.drawnLines-- {
+		fmt.Print("\x1b[1A\x1b[2K")
+	}
+}
+
+// progressWriter counts the bytes written for a file. Wrapping the destination
+// rather than the source keeps the io.WriterTo of remote files, which read
+// ahead concurrently.
+type progressWriter struct {
+	io.Writer
+	progress *progress
+	file     *fileProgress
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	n, err := w.Writer.Write(b)
+	w.progress.add(w.file, n)
+	return n, err
+}
+
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	divisor, exponent := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		divisor *= unit
+		exponent++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(divisor), "KMGTPE"[exponent])
+}
@@ -0,0 +1,119 @@
+package protocols
+
+import (
+	clientConfig "fileTransfer/configuration"
+	files "fileTransfer/filesystem"
+	"fileTransfer/output"
+	"fileTransfer/protocols/transport"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Pull downloads the remote files that changed since they were last transferred.
+// A remote file is considered changed when it is unknown to the snapshot, or when
+// its size or modification time differ from the ones recorded. When deleteMissing
+// is set, local files that were published but no longer exist on the server are
+// removed, unless they were modified locally since. Likewise, remote changes to
+// files modified locally are reported as conflicts rather than downloaded, unless
+// both sides have the same content.
+func Pull(conn transport.Transport, config clientConfig.Configuration, deleteMissing bool) error {
+	published, err := files.ReadFileList(config.Target)
+	if err != nil {
+		published = files.Filesystem{}
+	}
+	localFiles, err := files.CreateFileList(config.Target)
+	if err != nil {
+		return err
+	}
+
+	remoteFiles, err := listRemoteFiles(conn, config)
+	if err != nil {
+		return err
+	}
+
+	currentDirectory, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("cannot get current working directory: %v", err)
+	}
+
+	changedFiles := []files.FileData{}
+	remotePaths := map[string]bool{}
+	conflicts := []conflict{}
+	matching := map[string]time.Time{} // server modification time of the files already up to date
+	for _, remoteFile := range remoteFiles {
+		clientPath := localPath(currentDirectory, remoteFile)
+		remotePaths[clientPath] = true
+		publishedFile, ok := published[clientPath]
+		if ok && publishedFile.Size == remoteFile.Size && remoteFile.ModTime.Equal(publishedFile.PublishedModTime()) {
+			continue
+		}
+		localFile, isLocal := localFiles[clientPath]
+		isLocal = isLocal && !localFile.IsDeleted
+		localChanged := isLocal && localFile.HasChanged(published)
+		// files unknown to the snapshot, e.g. right after init, and snapshots
+		// written before the server modification time was recorded
+		if (localChanged || isLocal && publishedFile.RemoteModTime.IsZero()) && sameContent(conn, localFile, remoteFile) {
+			matching[clientPath] = remoteFile.ModTime
+			continue
+		}
+		// local edits aren't overwritten, sync keeps both versions instead
+		if localChanged {
+			conflicts = append(conflicts, conflict{clientPath, "modified locally and on server, not downloaded, use sync to keep both versions"})
+			continue
+		}
+		changedFiles = append(changedFiles, remoteFile)
+	}
+
+	s := downloadFiles(conn, config, currentDirectory, changedFiles)
+	s.Print()
+
+	// failed deletions stay in the snapshot, to be tried again by the next pull
+	var deletionErrors TransferErrors
+	deletedFiles := []string{}
+	if deleteMissing {
+		for filename := range published {
+			localFile, ok := localFiles[filename]
+			if remotePaths[filename] || !ok || localFile.HasChanged(published) || files.IsConflictCopy(filename) {
+				continue
+			}
+			err = os.Remove(filename)
+			if err != nil && !os.IsNotExist(err) {
+				err = fmt.Errorf("cannot delete local file (%s): %v", filename, err)
+				deletionErrors = append(deletionErrors, err)
+				output.Error(fanOutTarget(config), filename, err)
+				continue
+			}
+			log.Printf("deleted file: %s\n", filename)
+			output.Deleted(fanOutTarget(config), filename)
+			deletedFiles = append(deletedFiles, filename)
+		}
+	}
+
+	for _, c := range conflicts {
+		log.Printf("CONFLICT %s: %s\n", c.path, c.message)
+		output.Conflicted(c.path, c.message)
+	}
+
+	fs, err := files.CreateFileList(config.Target)
+	if err != nil {
+		return err
+	}
+	published.Refresh(fs)
+	s.publish(published, fs)
+	for filename, remoteModTime := range matching {
+		published.Publish(fs, filename, remoteModTime)
+	}
+	// files removed because of the server shouldn't be deleted remotely by the next publish
+	for _, filename := range deletedFiles {
+		delete(published, filename)
+	}
+	if err = published.Store(config.Target); err != nil {
+		return fmt.Errorf("cannot store filesystem changes: %v", err)
+	}
+	if len(deletionErrors) > 0 {
+		return append(s.errors, deletionErrors...)
+	}
+	return s.Err()
+}
@@ -0,0 +1,76 @@
+package protocols
+
+import (
+	"errors"
+	clientConfig "fileTransfer/configuration"
+	"fileTransfer/protocols/transport"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	jitterMutex  sync.Mutex
+	jitterSource = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// isRetryable tells whether err comes from a network failure or a corrupted
+// transfer, or from one of the replies the backend knows to be transient
+func isRetryable(conn transport.Transport, err error) bool {
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, errChecksumMismatch),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNABORTED),
+		errors.Is(err, syscall.EPIPE),
+		errors.Is(err, syscall.ETIMEDOUT):
+		return true
+	}
+	retrier, ok := conn.(transport.Retrier)
+	return ok && retrier.Retryable(err)
+}
+
+// retryDelay doubles the base delay at every attempt, without going over the
+// max delay, then shifts it randomly so that workers don't retry all at once
+func retryDelay(config clientConfig.Configuration, attempt int) time.Duration {
+	delay := config.RetryBaseDelay
+	for i := 1; i < attempt && (config.RetryMaxDelay <= 0 || delay < config.RetryMaxDelay) && delay < math.MaxInt64/2; i++ {
+		delay *= 2
+	}
+	if config.RetryMaxDelay > 0 && delay > config.RetryMaxDelay {
+		delay = config.RetryMaxDelay
+	}
+	if config.RetryJitter > 0 {
+		jitterMutex.Lock()
+		shift := jitterSource.Int63n(int64(2*config.RetryJitter)+1) - int64(config.RetryJitter)
+		jitterMutex.Unlock()
+		delay += delay * time.Duration(shift) / 100
+	}
+	return delay
+}
+
+// retry runs operation until it succeeds, fails with an error that isn't
+// transient or runs out of attempts. The connection is dialed again before
+// retrying if the server dropped it.
+func retry(conn transport.Transport, config clientConfig.Configuration, description string, operation func() error) error {
+	err := operation()
+	for attempt := 1; err != nil && attempt <= config.Retries && isRetryable(conn, err); attempt++ {
+		delay := retryDelay(config, attempt)
+		log.Printf("retrying %s in %v (attempt %d of %d): %v\n", description, delay, attempt, config.Retries, err)
+		time.Sleep(delay)
+		if retrier, ok := conn.(transport.Retrier); ok {
+			if err = retrier.Reconnect(); err != nil {
+				continue
+			}
+		}
+		err = operation()
+	}
+	return err
+}
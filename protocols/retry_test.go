@@ -0,0 +1,78 @@
+package protocols
+
+import (
+	"errors"
+	clientConfig "fileTransfer/configuration"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     time.Duration
+		max      time.Duration
+		attempt  int
+		expected time.Duration
+	}{
+		{"first attempt", time.Second, 30 * time.Second, 1, time.Second},
+		{"second attempt", time.Second, 30 * time.Second, 2, 2 * time.Second},
+		{"fifth attempt", time.Second, 30 * time.Second, 5, 16 * time.Second},
+		{"capped", time.Second, 30 * time.Second, 6, 30 * time.Second},
+		{"capped long after", time.Second, 30 * time.Second, 100, 30 * time.Second},
+		{"base over max", time.Minute, 30 * time.Second, 1, 30 * time.Second},
+		{"no max", time.Second, 0, 6, 32 * time.Second},
+		{"no max without overflowing", time.Second, 0, 100, time.Second << 33},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := clientConfig.Configuration{RetryBaseDelay: test.base, RetryMaxDelay: test.max}
+			if delay := retryDelay(config, test.attempt); delay != test.expected {
+				t.Errorf("retryDelay(%d) = %v, want %v", test.attempt, delay, test.expected)
+			}
+		})
+	}
+}
+
+func TestRetryDelayJitter(t *testing.T) {
+	config := clientConfig.Configuration{RetryBaseDelay: time.Second, RetryMaxDelay: 30 * time.Second, RetryJitter: 20}
+	for i := 0; i < 100; i++ {
+		if delay := retryDelay(config, 2); delay < 1600*time.Millisecond || delay > 2400*time.Millisecond {
+			t.Fatalf("retryDelay(2) = %v, want 2s ± 20%%", delay)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	errPermanent := errors.New("permission denied")
+	tests := []struct {
+		name     string
+		failures []error
+		retries  int
+		calls    int
+		err      error
+	}{
+		{"success", nil, 3, 1, nil},
+		{"transient failure", []error{io.ErrUnexpectedEOF}, 3, 2, nil},
+		{"out of attempts", []error{io.ErrUnexpectedEOF, io.ErrUnexpectedEOF, io.ErrUnexpectedEOF}, 2, 3, io.ErrUnexpectedEOF},
+		{"permanent failure", []error{errPermanent}, 3, 1, errPermanent},
+		{"retries disabled", []error{io.ErrUnexpectedEOF}, 0, 1, io.ErrUnexpectedEOF},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := clientConfig.Configuration{Retries: test.retries}
+			calls := 0
+			err := retry(nil, config, "test", func() error {
+				calls++
+				if calls <= len(test.failures) {
+					return test.failures[calls-1]
+				}
+				return nil
+			})
+			if err != test.err || calls != test.calls {
+				t.Errorf("retry() = %v after %d call(s), want %v after %d", err, calls, test.err, test.calls)
+			}
+		})
+	}
+}
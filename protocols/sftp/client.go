@@ -0,0 +1,273 @@
+package sftp
+
+import (
+	"errors"
+	clientConfig "fileTransfer/configuration"
+	files "fileTransfer/filesystem"
+	"fileTransfer/protocols/transport"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+func init() {
+	transport.Register("SFTP", Dial)
+}
+
+// Client implements transport.Transport on top of an SSH connection.
+// Operations borrow an SFTP subsystem from a pool of up to MaxConnections
+// idle sessions, so that concurrent workers never share one and opening a
+// session isn't paid for every file.
+type Client struct {
+	mutex        sync.RWMutex
+	reconnecting sync.Mutex
+	conn         *ssh.Client
+	sshConfig    *ssh.ClientConfig
+	address      string
+	closed       chan struct{}
+	idle         chan *session
+	debugMode    bool
+	opened       int64
+	acquired     int64
+	noHash       int32
+}
+
+// file gives the SFTP session it was opened with back to the pool on close
+type file struct {
+	*sftp.File
+	client  *Client
+	session *session
+}
+
+func (f *file) Close() error {
+	err := f.File.Close()
+	f.client.release(f.session, err)
+	return err
+}
+
+// Sync is skipped on servers without the fsync extension, rather than failing
+// every upload
+func (f *file) Sync() error {
+	if _, ok := f.session.HasExtension("fsync@openssh.com"); !ok {
+		return nil
+	}
+	return f.File.Sync()
+}
+
+func Dial(sftpConfig clientConfig.Configuration, password transport.Password) (transport.Transport, error) {
+	// the handshake only reports that authentication failed, not why the password is missing
+	var passwordErr error
+	sshConfig, err := newSSHConfig(sftpConfig, func() (string, error) {
+		secret, err := password()
+		passwordErr = err
+		return secret, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	conn, err := ssh.Dial("tcp", address(sftpConfig), sshConfig)
+	if err != nil {
+		if passwordErr != nil {
+			return nil, passwordErr
+		}
+		return nil, err
+	}
+	poolSize := sftpConfig.MaxConnections
+	if poolSize < 1 {
+		poolSize = 1
+	}
+	c := &Client{conn: conn, sshConfig: sshConfig, address: address(sftpConfig), closed: make(chan struct{}),
+		idle: make(chan *session, poolSize), debugMode: sftpConfig.DebugMode}
+	go c.keepAlive()
+	return c, nil
+}
+
+func (c *Client) connection() *ssh.Client {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.conn
+}
+
+// Retryable accepts the status codes of a lost connection, and the end of
+// stream an SSH session gets when the server closes it
+func (c *Client) Retryable(err error) bool {
+	var statusErr *sftp.StatusError
+	if errors.As(err, &statusErr) {
+		code := statusErr.FxCode()
+		return code == sftp.ErrSSHFxNoConnection || code == sftp.ErrSSHFxConnectionLost
+	}
+	return errors.Is(err, sftp.ErrSSHFxNoConnection) || errors.Is(err, sftp.ErrSSHFxConnectionLost) ||
+		errors.Is(err, io.EOF)
+}
+
+func (c *Client) withClient(fn func(client *sftp.Client) error) error {
+	s, err := c.acquire()
+	if err != nil {
+		return err
+	}
+	err = fn(s.Client)
+	c.release(s, err)
+	return err
+}
+
+func listFiles(client *sftp.Client, root string, remoteDir string) ([]files.FileData, error) {
+	remoteFiles, err := client.ReadDir(remoteDir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list remote dir: %v", err)
+	}
+
+	var listedFiles []files.FileData
+	for _, f := range remoteFiles {
+		fullPath := path.Join(remoteDir, f.Name())
+		if !f.IsDir() {
+			listedFiles = append(listedFiles, files.FileData{
+				RelativePath: transport.Rel(root, fullPath),
+				AbsolutePath: fullPath,
+				Size:         f.Size(),
+				ModTime:      f.ModTime(),
+			})
+		} else {
+			remoteFiles2, err := listFiles(client, root, fullPath)
+			if err != nil {
+				return nil, err
+			}
+			listedFiles = append(listedFiles, remoteFiles2...)
+		}
+	}
+
+	return listedFiles, nil
+}
+
+func (c *Client) List(root string) (list []files.FileData, err error) {
+	err = c.withClient(func(client *sftp.Client) error {
+		list, err = listFiles(client, root, root)
+		return err
+	})
+	return list, err
+}
+
+func (c *Client) Stat(path string) (info os.FileInfo, err error) {
+	err = c.withClient(func(client *sftp.Client) error {
+		info, err = client.Lstat(path)
+		return err
+	})
+	return info, err
+}
+
+func (c *Client) Open(path string) (io.ReadCloser, error) {
+	s, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.Open(path)
+	if err != nil {
+		c.release(s, err)
+		return nil, err
+	}
+	return &file{f, c, s}, nil
+}
+
+func (c *Client) Create(path string) (io.WriteCloser, error) {
+	s, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.Create(path)
+	if err != nil {
+		c.release(s, err)
+		return nil, err
+	}
+	return &file{f, c, s}, nil
+}
+
+func (c *Client) OpenAt(path string, offset int64) (io.ReadCloser, error) {
+	f, err := c.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = f.(*file).Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// Append doesn't rely on the APPEND open flag, which not every server honours
+func (c *Client) Append(path string) (io.WriteCloser, error) {
+	s, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	f, err := s.OpenFile(path, os.O_WRONLY)
+	if err != nil {
+		c.release(s, err)
+		return nil, err
+	}
+	if _, err = f.Seek(0, io.SeekEnd); err != nil {
+		f.Close()
+		c.release(s, err)
+		return nil, err
+	}
+	return &file{f, c, s}, nil
+}
+
+func (c *Client) MkdirAll(path string) error {
+	return c.withClient(func(client *sftp.Client) error {
+		return client.MkdirAll(path)
+	})
+}
+
+func (c *Client) Remove(path string) error {
+	return c.withClient(func(client *sftp.Client) error {
+		return client.Remove(path)
+	})
+}
+
+func (c *Client) RemoveDirectory(path string) error {
+	return c.withClient(func(client *sftp.Client) error {
+		return client.RemoveDirectory(path)
+	})
+}
+
+// Rename replaces newPath if it already exists. Without the posix-rename
+// extension the replacement isn't atomic, newPath being removed first.
+func (c *Client) Rename(oldPath, newPath string) error {
+	return c.withClient(func(client *sftp.Client) error {
+		if _, ok := client.HasExtension("posix-rename@openssh.com"); ok {
+			return client.PosixRename(oldPath, newPath)
+		}
+		err := client.Rename(oldPath, newPath)
+		if err == nil || !targetExists(client, err, oldPath, newPath) {
+			return err
+		}
+		if err = client.Remove(newPath); err != nil {
+			return err
+		}
+		return client.Rename(oldPath, newPath)
+	})
+}
+
+// targetExists tells if a rename failed only because newPath is an existing
+// file, which servers report as a generic failure
+func targetExists(client *sftp.Client, err error, oldPath, newPath string) bool {
+	var statusErr *sftp.StatusError
+	if !errors.As(err, &statusErr) || statusErr.FxCode() != sftp.ErrSSHFxFailure {
+		return false
+	}
+	if _, err := client.Lstat(oldPath); err != nil {
+		return false
+	}
+	info, err := client.Lstat(newPath)
+	return err == nil && !info.IsDir()
+}
+
+func (c *Client) Close() error {
+	close(c.closed)
+	c.closeSessions()
+	return c.connection().Close()
+}
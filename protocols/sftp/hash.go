@@ -0,0 +1,41 @@
+package sftp
+
+import (
+	"errors"
+	"fileTransfer/protocols/transport"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// commandNotFound is the exit status of a shell that doesn't know the command
+const commandNotFound = 127
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// Hash runs sha256sum over an SSH exec session, pkg/sftp giving no access to
+// the check-file extension. Accounts restricted to SFTP can't run commands, so
+// the failure is remembered for the next files.
+func (c *Client) Hash(path string) (string, error) {
+	if atomic.LoadInt32(&c.noHash) == 1 {
+		return "", transport.ErrHashUnsupported
+	}
+	session, err := c.connection().NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+	output, err := session.Output("sha256sum " + shellQuote(path))
+	fields := strings.Fields(string(output))
+	if err == nil && len(fields) > 0 && len(fields[0]) == 64 {
+		return strings.ToLower(fields[0]), nil
+	}
+	var exitErr *ssh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitStatus() == commandNotFound {
+		atomic.StoreInt32(&c.noHash, 1)
+	}
+	return "", transport.ErrHashUnsupported
+}
@@ -0,0 +1,74 @@
+package sftp
+
+import (
+	"log"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	keepAliveInterval = 15 * time.Second
+	// a connection whose TCP peer vanished never answers, so the wait is bounded
+	keepAliveTimeout = 10 * time.Second
+)
+
+// isAlive sends a keepalive request, whose reply tells the session still works
+// even when the server doesn't know the request
+func isAlive(conn *ssh.Client) bool {
+	reply := make(chan error, 1)
+	go func() {
+		_, _, err := conn.SendRequest("keepalive@openssh.com", true, nil)
+		reply <- err
+	}()
+	select {
+	case err := <-reply:
+		return err == nil
+	case <-time.After(keepAliveTimeout):
+		return false
+	}
+}
+
+// keepAlive checks the connection periodically until the client is closed, so
+// that a dead session is replaced before workers need it
+func (c *Client) keepAlive() {
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.closed:
+			return
+		case <-ticker.C:
+			if err := c.Reconnect(); err != nil {
+				log.Printf("cannot reconnect to %s: %v\n", c.address, err)
+			}
+		}
+	}
+}
+
+// Reconnect dials the server again with the same configuration, only if the
+// connection is dead, so that the workers failing together replace it once.
+// Reconnections are serialized without holding the connection lock, workers
+// using the connection meanwhile rather than waiting for the probe.
+func (c *Client) Reconnect() error {
+	c.reconnecting.Lock()
+	defer c.reconnecting.Unlock()
+	probed := c.connection()
+	if isAlive(probed) {
+		return nil
+	}
+	log.Printf("SSH connection to %s lost, reconnecting\n", c.address)
+	conn, err := ssh.Dial("tcp", c.address, c.sshConfig)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.conn != probed {
+		conn.Close()
+		return nil
+	}
+	c.conn.Close()
+	c.conn = conn
+	return nil
+}
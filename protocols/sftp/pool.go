@@ -0,0 +1,82 @@
+package sftp
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// session is an SFTP subsystem along with the connection it was opened on
+type session struct {
+	*sftp.Client
+	conn *ssh.Client
+}
+
+// newSession opens an SFTP subsystem, dialing the server again first if the
+// connection was lost, so that workers go on with the new connection
+func (c *Client) newSession() (*session, error) {
+	conn := c.connection()
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		if reconnectErr := c.Reconnect(); reconnectErr != nil {
+			return nil, fmt.Errorf("failed to instantiate new SFTP client: %w", err)
+		}
+		conn = c.connection()
+		client, err = sftp.NewClient(conn)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to instantiate new SFTP client: %w", err)
+	}
+	atomic.AddInt64(&c.opened, 1)
+	return &session{client, conn}, nil
+}
+
+// acquire takes an idle session from the pool, or opens a new one when all of
+// them are in use. Sessions opened before a reconnection are dropped.
+func (c *Client) acquire() (*session, error) {
+	atomic.AddInt64(&c.acquired, 1)
+	for {
+		select {
+		case s := <-c.idle:
+			if s.conn == c.connection() {
+				return s, nil
+			}
+			s.Close()
+		default:
+			return c.newSession()
+		}
+	}
+}
+
+// release gives a session back to the pool, unless the operation failed in a
+// way that may have broken it or the pool is already full
+func (c *Client) release(s *session, err error) {
+	if err != nil && c.Retryable(err) {
+		s.Close()
+		return
+	}
+	select {
+	case c.idle <- s:
+	default:
+		s.Close()
+	}
+}
+
+// closeSessions closes the idle sessions, logging how much they were reused in debug mode
+func (c *Client) closeSessions() {
+	for {
+		select {
+		case s := <-c.idle:
+			s.Close()
+		default:
+			if c.debugMode {
+				log.Printf("SFTP pool of %d session(s): %d opened for %d operation(s)\n",
+					cap(c.idle), atomic.LoadInt64(&c.opened), atomic.LoadInt64(&c.acquired))
+			}
+			return
+		}
+	}
+}
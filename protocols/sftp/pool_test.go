@@ -0,0 +1,123 @@
+package sftp
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// serveSFTP answers the SFTP subsystem requests of an in-process SSH server
+func serveSFTP(b *testing.B, conn net.Conn) {
+	signer, err := newHostKey()
+	if err != nil {
+		b.Error(err)
+		return
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		b.Error(err)
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for request := range channelRequests {
+				isSFTP := request.Type == "subsystem" && string(request.Payload[4:]) == "sftp"
+				request.Reply(isSFTP, nil)
+				if isSFTP {
+					server, err := sftp.NewServer(channel)
+					if err == nil {
+						go func() {
+							server.Serve()
+							channel.Close()
+						}()
+					}
+				}
+			}
+		}()
+	}
+}
+
+func newHostKey() (ssh.Signer, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewSignerFromKey(private)
+}
+
+// newLoopbackClient connects a Client keeping up to poolSize idle sessions to an
+// in-process server, a pool size of 0 opening a session for every operation
+func newLoopbackClient(b *testing.B, poolSize int) *Client {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		serverSide, err := listener.Accept()
+		if err == nil {
+			serveSFTP(b, serverSide)
+		}
+	}()
+	clientSide, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	sshConn, channels, requests, err := ssh.NewClientConn(clientSide, listener.Addr().String(),
+		&ssh.ClientConfig{User: "test", HostKeyCallback: ssh.InsecureIgnoreHostKey()})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return &Client{conn: ssh.NewClient(sshConn, channels, requests), address: listener.Addr().String(),
+		closed: make(chan struct{}), idle: make(chan *session, poolSize)}
+}
+
+// BenchmarkUpload measures the per-file overhead of opening SFTP sessions, by
+// uploading small files from concurrent workers with and without a pool of
+// reusable sessions
+func BenchmarkUpload(b *testing.B) {
+	content := make([]byte, 1024)
+	for _, poolSize := range []int{0, 1, 4} {
+		b.Run(fmt.Sprintf("pool=%d", poolSize), func(b *testing.B) {
+			c := newLoopbackClient(b, poolSize)
+			defer c.Close()
+			folder := b.TempDir()
+			var files int64
+			// at least as many workers as the largest pool
+			b.SetParallelism(4)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					i := atomic.AddInt64(&files, 1)
+					f, err := c.Create(filepath.ToSlash(filepath.Join(folder, fmt.Sprintf("file%d", i%100))))
+					if err != nil {
+						b.Error(err)
+						return
+					}
+					if _, err = f.Write(content); err != nil {
+						b.Error(err)
+					}
+					if err = f.Close(); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+			b.StopTimer()
+			b.ReportMetric(float64(atomic.LoadInt64(&c.opened))/float64(b.N), "sessions/op")
+		})
+	}
+}
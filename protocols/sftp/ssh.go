@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"errors"
 	clientConfig "fileTransfer/configuration"
+	"fileTransfer/protocols/transport"
 	"fileTransfer/terminal"
 	"fmt"
 	"io/ioutil"
@@ -94,25 +95,30 @@ func isLocalhost(address net.Addr) bool {
 	return false
 }
 
-func Connect(sftpConfig clientConfig.Configuration, password string) (*ssh.Client, error) {
+// newSSHConfig is built once per run, so that reconnecting authenticates the
+// same way without reading the private key again. The private key is tried
+// first, the password is only asked for if the server refuses it.
+func newSSHConfig(sftpConfig clientConfig.Configuration, password transport.Password) (*ssh.ClientConfig, error) {
 	var keyErr *knownhosts.KeyError
 
+	auth := []ssh.AuthMethod{}
 	privateKeyFilename := getPrivateKeyFilename()
 	privateKey, err := ioutil.ReadFile(privateKeyFilename)
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		return nil, fmt.Errorf("unable to read private key: %v", err)
 	}
-	signer, err := ssh.ParsePrivateKey(privateKey)
-	if err != nil {
-		return nil, fmt.Errorf("unable to parse private key: %v", err)
+	if err == nil {
+		signer, err := ssh.ParsePrivateKey(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse private key: %v", err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
 	}
+	auth = append(auth, ssh.PasswordCallback(password))
 
 	config := &ssh.ClientConfig{
 		User: sftpConfig.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(password),
-			ssh.PublicKeys(signer),
-		},
+		Auth: auth,
 		HostKeyCallback: ssh.HostKeyCallback(func(host string, remote net.Addr, pubKey ssh.PublicKey) error {
 			if isLocalhost(remote) {
 				log.Print("Skipping SSH known_hosts verification for localhost")
@@ -139,10 +145,9 @@ func Connect(sftpConfig clientConfig.Configuration, password string) (*ssh.Clien
 			return nil
 		}),
 	}
-	hostAndPort := fmt.Sprintf("%s:%d", sftpConfig.Hostname, sftpConfig.Port)
-	conn, err := ssh.Dial("tcp", hostAndPort, config)
-	if err != nil {
-		return nil, err
-	}
-	return conn, nil
+	return config, nil
+}
+
+func address(sftpConfig clientConfig.Configuration) string {
+	return fmt.Sprintf("%s:%d", sftpConfig.Hostname, sftpConfig.Port)
 }
@@ -2,52 +2,282 @@ package protocols
 
 import (
 	clientConfig "fileTransfer/configuration"
-	"fileTransfer/protocols/ftp"
-	"fileTransfer/protocols/sftp"
+	files "fileTransfer/filesystem"
+	"fileTransfer/output"
+	"fileTransfer/protocols/transport"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
 
-	"errors"
+func Connect(config clientConfig.Configuration, password transport.Password) (transport.Transport, error) {
+	return transport.Dial(config, password)
+}
 
-	"github.com/secsy/goftp"
-	"golang.org/x/crypto/ssh"
-)
+func serverFolder(config clientConfig.Configuration) string {
+	return path.Join("/", filepath.ToSlash(config.ServerFolder))
+}
+
+// listRemoteFiles lists the server folder, leaving out the ignored files
+func listRemoteFiles(conn transport.Transport, config clientConfig.Configuration) ([]files.FileData, error) {
+	var remoteFiles []files.FileData
+	err := retry(conn, config, "listing of remote files", func() (err error) {
+		remoteFiles, err = conn.List(serverFolder(config))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot list all remote files: %w", err)
+	}
+	ignoreList, err := files.ReadIgnoreList()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read ignored files: %v", err)
+	}
+	return ignoreList.Filter(remoteFiles), nil
+}
+
+func Clone(conn transport.Transport, config clientConfig.Configuration) error {
+	remoteFiles, err := listRemoteFiles(conn, config)
+	if err != nil {
+		return err
+	}
+
+	currentDirectory, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("cannot get current working directory: %v", err)
+	}
+
+	if config.DryRun {
+		planDownloads(currentDirectory, remoteFiles).Print("download")
+		return nil
+	}
+	published, err := files.ReadFileList(config.Target)
+	if err != nil {
+		published = files.Filesystem{}
+	}
+	s := downloadFiles(conn, config, currentDirectory, remoteFiles)
+	s.Print()
+
+	// the downloaded files are known to match the server, the baseline for publish, pull and sync
+	fs, err := files.CreateFileList(config.Target)
+	if err != nil {
+		return err
+	}
+	published.Refresh(fs)
+	s.publish(published, fs)
+	if err = published.Store(config.Target); err != nil {
+		return fmt.Errorf("cannot store filesystem changes: %v", err)
+	}
+	return s.Err()
+}
+
+func localPath(currentDirectory string, remoteFile files.FileData) string {
+	return filepath.Join(currentDirectory, filepath.FromSlash(remoteFile.RelativePath))
+}
+
+func downloadFiles(conn transport.Transport, config clientConfig.Configuration, currentDirectory string, remoteFiles []files.FileData) *summary {
+	s := newSummary("download", fanOutTarget(config))
+	var totalBytes int64
+	for _, remoteFile := range remoteFiles {
+		totalBytes += remoteFile.Size
+	}
+	options := transferOptions{config.Target, fanOutTarget(config), config.Verify,
+		newBucket(bandwidthLimit(config.DownloadLimit, config.BandwidthLimit)), newProgress("download", fanOutTarget(config), len(remoteFiles), totalBytes)}
+	options.progress.Start()
+	defer options.progress.Stop()
+	var wg sync.WaitGroup
+	limitGuard := make(chan struct{}, config.MaxConnections)
 
-type ProtocolClient interface {
-	Close() error
+	for _, remoteFile := range remoteFiles {
+		limitGuard <- struct{}{}
+		if config.FailFast && s.hasFailed() {
+			<-limitGuard
+			s.skip(localPath(currentDirectory, remoteFile))
+			continue
+		}
+		wg.Add(1)
+
+		go func(remoteFile files.FileData) {
+			defer wg.Done()
+			defer func() { <-limitGuard }()
+			clientPath := localPath(currentDirectory, remoteFile)
+			var copiedBytes int64
+			err := retry(conn, config, "download of "+remoteFile.AbsolutePath, func() (err error) {
+				copiedBytes, err = downloadFile(conn, clientPath, remoteFile, options)
+				return err
+			})
+			s.add(clientPath, false, copiedBytes, remoteFile.ModTime, err)
+		}(remoteFile)
+	}
+	wg.Wait()
+	return s
 }
 
-func Connect(config clientConfig.Configuration, password string) (ProtocolClient, error) {
-	switch config.Protocol {
-	case "SFTP":
-		return sftp.Connect(config, password)
-	case "FTP", "FTPS-IMPLICIT", "FTPS-EXPLICIT":
-		return ftp.Connect(config, password)
-	default:
-		return nil, raiseUnexpectedProtocolError(config)
+func PushChanges(conn transport.Transport, config clientConfig.Configuration) error {
+	fs, err := files.CreateFileList(config.Target)
+	if err != nil {
+		return err
 	}
+	return pushChanges(conn, config, fs, newBucket(bandwidthLimit(config.UploadLimit, config.BandwidthLimit)))
 }
 
-func Clone(conn ProtocolClient, config clientConfig.Configuration) error {
-	switch config.Protocol {
-	case "SFTP":
-		return sftp.Clone(conn.(*ssh.Client), config)
-	case "FTP", "FTPS-IMPLICIT", "FTPS-EXPLICIT":
-		return ftp.Clone(conn.(*goftp.Client), config)
-	default:
-		return raiseUnexpectedProtocolError(config)
+// PushChangesToAll publishes the working copy to several targets at once,
+// walking it only once. Each target sends what differs from its own snapshot,
+// which only records what that target received, so a failing server doesn't
+// mark the changes as published for the others. The error of each target is
+// returned by name, nil if it succeeded.
+func PushChangesToAll(conns []transport.Transport, configs []clientConfig.Configuration) map[string]error {
+	targets := []string{}
+	for _, config := range configs {
+		targets = append(targets, config.Target)
+	}
+	errs := map[string]error{}
+	lists, err := files.CreateFileLists(targets)
+	if err != nil {
+		for _, target := range targets {
+			errs[target] = err
+		}
+		return errs
+	}
+
+	// the limit is shared by all the targets, the strictest one applying to the whole run
+	limit := 0
+	for _, config := range configs {
+		if targetLimit := bandwidthLimit(config.UploadLimit, config.BandwidthLimit); targetLimit > 0 && (limit == 0 || targetLimit < limit) {
+			limit = targetLimit
+		}
+	}
+	bucket := newBucket(limit)
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	for i, config := range configs {
+		config.FanOut = true
+		// plans are printed one after another rather than mixed up
+		if config.DryRun {
+			errs[config.Target] = pushChanges(conns[i], config, lists[config.Target], bucket)
+			continue
+		}
+		wg.Add(1)
+		go func(conn transport.Transport, config clientConfig.Configuration) {
+			defer wg.Done()
+			err := pushChanges(conn, config, lists[config.Target], bucket)
+			mutex.Lock()
+			defer mutex.Unlock()
+			errs[config.Target] = err
+		}(conns[i], config)
 	}
+	wg.Wait()
+	return errs
 }
 
-func PushChanges(conn ProtocolClient, config clientConfig.Configuration) error {
-	switch config.Protocol {
-	case "SFTP":
-		return sftp.PushChanges(conn.(*ssh.Client), config)
-	case "FTP", "FTPS-IMPLICIT", "FTPS-EXPLICIT":
-		return ftp.PushChanges(conn.(*goftp.Client), config)
-	default:
-		return raiseUnexpectedProtocolError(config)
+// pushChanges publishes the files of fs that differ from the snapshot of the target
+func pushChanges(conn transport.Transport, config clientConfig.Configuration, fs files.Filesystem, limit *bucket) error {
+	published, err := files.ReadFileList(config.Target)
+	if err != nil {
+		published = files.Filesystem{}
+	}
+	// the snapshot records the files one by one as they are published
+	filesList := fs.List(published)
+
+	if config.DryRun {
+		if config.FanOut && !output.JSON() {
+			fmt.Printf("Target %s:\n", config.Target)
+		}
+		planUploads(conn, config, filesList).Print("upload")
+		return nil
+	}
+
+	removeStaleUploads(conn, config, filesList)
+	s := uploadFiles(conn, config, filesList, limit)
+	s.Print()
+	if config.PruneEmptyFolders {
+		pruneEmptyFolders(conn, config, filesList)
+	}
+	published.Refresh(fs)
+	s.publish(published, fs)
+	if err = published.Store(config.Target); err != nil {
+		return fmt.Errorf("cannot store filesystem changes: %v", err)
 	}
+	return s.Err()
 }
 
-func raiseUnexpectedProtocolError(config clientConfig.Configuration) error {
-	return errors.New("Unexpected protocol: " + config.Protocol)
+func remotePath(config clientConfig.Configuration, localFile files.FileData) string {
+	return path.Join(serverFolder(config), filepath.ToSlash(localFile.RelativePath))
+}
+
+// uploadFiles sends filesList with up to MaxConnections workers, limit being
+// the upload bucket of the run
+func uploadFiles(conn transport.Transport, config clientConfig.Configuration, filesList []files.FileData, limit *bucket) *summary {
+	s := newSummary("upload", fanOutTarget(config))
+	totalFiles, totalBytes := 0, int64(0)
+	for _, localFile := range filesList {
+		if !localFile.IsDeleted {
+			totalFiles++
+			totalBytes += localFile.Size
+		}
+	}
+	options := transferOptions{config.Target, fanOutTarget(config), config.Verify, limit, newProgress("upload", fanOutTarget(config), totalFiles, totalBytes)}
+	options.progress.Start()
+	defer options.progress.Stop()
+	var wg sync.WaitGroup
+	limitGuard := make(chan struct{}, config.MaxConnections)
+
+	for _, localFile := range filesList {
+		limitGuard <- struct{}{}
+		if config.FailFast && s.hasFailed() {
+			<-limitGuard
+			s.skip(localFile.AbsolutePath)
+			continue
+		}
+		wg.Add(1)
+
+		go func(localFile files.FileData) {
+			defer wg.Done()
+			defer func() { <-limitGuard }()
+			var copiedBytes int64
+			var remoteModTime time.Time
+			err := retry(conn, config, "upload of "+localFile.AbsolutePath, func() (err error) {
+				copiedBytes, remoteModTime, err = uploadFile(conn, localFile, remotePath(config, localFile), options)
+				return err
+			})
+			s.add(localFile.AbsolutePath, localFile.IsDeleted, copiedBytes, remoteModTime, err)
+		}(localFile)
+	}
+	wg.Wait()
+	return s
+}
+
+// pruneEmptyFolders removes the server folders that deleted files may have left
+// empty, deepest first, never going above the server folder
+func pruneEmptyFolders(conn transport.Transport, config clientConfig.Configuration, filesList []files.FileData) {
+	root := serverFolder(config)
+	folders := map[string]bool{}
+	for _, localFile := range filesList {
+		if !localFile.IsDeleted {
+			continue
+		}
+		for folder := path.Dir(remotePath(config, localFile)); folder != root && folder != "/"; folder = path.Dir(folder) {
+			folders[folder] = true
+		}
+	}
+	sortedFolders := []string{}
+	for folder := range folders {
+		sortedFolders = append(sortedFolders, folder)
+	}
+	sort.Slice(sortedFolders, func(i, j int) bool {
+		return strings.Count(sortedFolders[i], "/") > strings.Count(sortedFolders[j], "/")
+	})
+	for _, folder := range sortedFolders {
+		// folders that aren't empty fail to be removed
+		if err := conn.RemoveDirectory(folder); err == nil {
+			log.Printf("deleted empty folder: %s\n", folder)
+			output.Deleted(fanOutTarget(config), folder)
+		}
+	}
 }
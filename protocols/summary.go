@@ -0,0 +1,116 @@
+package protocols
+
+import (
+	clientConfig "fileTransfer/configuration"
+	files "fileTransfer/filesystem"
+	"fileTransfer/output"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TransferErrors collects the errors of all the files that failed during a run
+type TransferErrors []error
+
+func (te TransferErrors) Error() string {
+	messages := make([]string, len(te))
+	for i, err := range te {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d file(s) failed:\n%s", len(te), strings.Join(messages, "\n"))
+}
+
+// summary counts what the workers of a run did, it's safe for concurrent use
+type summary struct {
+	mutex       sync.Mutex
+	target      string // only set when publishing to several targets at once
+	direction   string
+	started     time.Time
+	transferred int
+	deleted     int
+	skipped     int
+	bytes       int64
+	errors      TransferErrors
+	done        map[string]time.Time // server modification time of the files transferred, by local path
+}
+
+// newSummary starts counting the transfers of a direction, upload or download.
+// The target is given when publishing to several at once, to tell them apart.
+func newSummary(direction string, target string) *summary {
+	return &summary{target: target, direction: direction, started: time.Now(), done: map[string]time.Time{}}
+}
+
+// fanOutTarget returns the target of a run publishing to several targets at once,
+// or nothing otherwise
+func fanOutTarget(config clientConfig.Configuration) string {
+	if config.FanOut {
+		return config.Target
+	}
+	return ""
+}
+
+func (s *summary) add(localPath string, isDeletion bool, copiedBytes int64, remoteModTime time.Time, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err != nil {
+		s.errors = append(s.errors, err)
+		output.Error(s.target, localPath, err)
+		return
+	}
+	s.done[localPath] = remoteModTime
+	if isDeletion {
+		s.deleted++
+	} else {
+		s.transferred++
+		s.bytes += copiedBytes
+	}
+}
+
+// skip records a file that wasn't even tried because of an earlier failure
+func (s *summary) skip(localPath string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.skipped++
+	output.Skipped(s.target, localPath, "stopped after a failure")
+}
+
+func (s *summary) Print() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	duration := time.Since(s.started)
+	prefix := ""
+	if s.target != "" {
+		prefix = s.target + ": "
+	}
+	log.Printf("%s%sed %d file(s) [%d bytes], deleted %d, failed %d, skipped %d, in %v\n",
+		prefix, s.direction, s.transferred, s.bytes, s.deleted, len(s.errors), s.skipped, duration.Round(time.Millisecond))
+	output.Summary(s.target, s.direction, s.transferred, s.bytes, s.deleted, len(s.errors), s.skipped, duration)
+}
+
+func (s *summary) hasFailed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.errors) > 0
+}
+
+// publish records the files transferred successfully in the published state,
+// taking their local state from current. Files that failed keep their previous
+// state and are transferred again next time.
+func (s *summary) publish(published files.Filesystem, current files.Filesystem) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for filename, remoteModTime := range s.done {
+		published.Publish(current, filename, remoteModTime)
+	}
+}
+
+func (s *summary) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.errors) == 0 {
+		return nil
+	}
+	return s.errors
+}
@@ -0,0 +1,194 @@
+package protocols
+
+import (
+	clientConfig "fileTransfer/configuration"
+	files "fileTransfer/filesystem"
+	"fileTransfer/output"
+	"fileTransfer/protocols/transport"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Sync transfers local changes to the server and remote changes to the working
+// copy. Both sides are compared against the published state of the snapshot: a path
+// changed on one side only is transferred in that direction, a path changed on
+// both sides is a conflict, unless both sides have the same content. Conflicting
+// edits keep both versions, the local one being renamed with a ".conflict-<time>"
+// suffix before the remote one is downloaded, while an edit always wins over a
+// deletion.
+func Sync(conn transport.Transport, config clientConfig.Configuration) error {
+	baseline, err := files.ReadFileList(config.Target)
+	if err != nil {
+		baseline = files.Filesystem{}
+	}
+	localFiles, err := files.CreateFileList(config.Target)
+	if err != nil {
+		return err
+	}
+	remoteList, err := listRemoteFiles(conn, config)
+	if err != nil {
+		return err
+	}
+
+	currentDirectory, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("cannot get current working directory: %v", err)
+	}
+	remoteFiles := files.Filesystem{}
+	for _, remoteFile := range remoteList {
+		remoteFiles[localPath(currentDirectory, remoteFile)] = remoteFile
+	}
+
+	plan := planSync(baseline, localFiles, remoteFiles, func(localFile files.FileData, remoteFile files.FileData) bool {
+		return sameContent(conn, localFile, remoteFile)
+	})
+	for _, filename := range plan.conflictCopies {
+		conflictFilename := conflictCopyName(filename)
+		err = os.Rename(filename, conflictFilename)
+		if err != nil {
+			return fmt.Errorf("cannot keep conflicting local file (%s): %v", filename, err)
+		}
+		plan.conflicts = append(plan.conflicts, conflict{filename, "modified on both sides, local version kept as " + conflictFilename})
+	}
+
+	removeStaleUploads(conn, config, plan.uploads)
+	uploaded := uploadFiles(conn, config, plan.uploads, newBucket(bandwidthLimit(config.UploadLimit, config.BandwidthLimit)))
+	uploaded.Print()
+	if config.PruneEmptyFolders {
+		pruneEmptyFolders(conn, config, plan.uploads)
+	}
+	downloaded := downloadFiles(conn, config, currentDirectory, plan.downloads)
+	downloaded.Print()
+	// failed deletions stay in the snapshot, to be tried again by the next sync
+	var deletionErrors TransferErrors
+	deleted := []string{}
+	for _, filename := range plan.localDeletions {
+		err = os.Remove(filename)
+		if err != nil && !os.IsNotExist(err) {
+			err = fmt.Errorf("cannot delete local file (%s): %v", filename, err)
+			deletionErrors = append(deletionErrors, err)
+			output.Error(fanOutTarget(config), filename, err)
+			continue
+		}
+		deleted = append(deleted, filename)
+		log.Printf("deleted file: %s\n", filename)
+		output.Deleted(fanOutTarget(config), filename)
+	}
+
+	for _, c := range plan.conflicts {
+		log.Printf("CONFLICT %s: %s\n", c.path, c.message)
+		output.Conflicted(c.path, c.message)
+	}
+
+	fs, err := files.CreateFileList(config.Target)
+	if err != nil {
+		return err
+	}
+	baseline.Refresh(fs)
+	// uploads are recorded with the content they were sent with, even if it changed since
+	uploaded.publish(baseline, localFiles)
+	downloaded.publish(baseline, fs)
+	for _, filename := range plan.matching {
+		baseline.Publish(localFiles, filename, remoteFiles[filename].ModTime)
+	}
+	for _, filename := range append(deleted, plan.forgotten...) {
+		delete(baseline, filename)
+	}
+	if err = baseline.Store(config.Target); err != nil {
+		return fmt.Errorf("cannot store filesystem changes: %v", err)
+	}
+	failures := TransferErrors{}
+	failures = append(failures, uploaded.errors...)
+	failures = append(failures, downloaded.errors...)
+	failures = append(failures, deletionErrors...)
+	if len(failures) > 0 {
+		return failures
+	}
+	return nil
+}
+
+// syncPlan is what sync does with every path of the working copy and of the server
+type syncPlan struct {
+	uploads        []files.FileData
+	downloads      []files.FileData
+	localDeletions []string
+	// forgotten were deleted on both sides, only the snapshot still holding them
+	forgotten []string
+	// conflictCopies were modified differently on both sides, the local version
+	// being kept under another name before the server one is downloaded
+	conflictCopies []string
+	// matching were modified on both sides, but have the same content
+	matching  []string
+	conflicts []conflict
+}
+
+// planSync classifies every path by comparing both sides with the baseline.
+// sameContent is only called for the paths changed on both sides, or changed on
+// the server since a snapshot that didn't record its modification time.
+func planSync(baseline files.Filesystem, localFiles files.Filesystem, remoteFiles files.Filesystem,
+	sameContent func(localFile files.FileData, remoteFile files.FileData) bool) syncPlan {
+	plan := syncPlan{}
+	for filename := range localFiles.Add(remoteFiles) {
+		if files.IsConflictCopy(filename) {
+			continue
+		}
+		localFile, isLocal := localFiles[filename]
+		remoteFile, isRemote := remoteFiles[filename]
+		baseFile, isBase := baseline[filename]
+
+		localChanged := isLocal && localFile.HasChanged(baseline)
+		localDeleted := isLocal && localFile.IsDeleted
+		remoteChanged := isRemote && (!isBase || remoteFile.Size != baseFile.Size ||
+			!remoteFile.ModTime.Equal(baseFile.PublishedModTime()))
+		// the snapshot only holds files known to the server
+		remoteDeleted := !isRemote && isBase
+
+		switch {
+		case localDeleted && remoteDeleted:
+			plan.forgotten = append(plan.forgotten, filename)
+		case localChanged && remoteChanged && localDeleted:
+			plan.conflicts = append(plan.conflicts, conflict{filename, "deleted locally but modified on server, keeping server version"})
+			plan.downloads = append(plan.downloads, remoteFile)
+		case localChanged && remoteChanged:
+			// e.g. every file right after init, or uploaded without keeping its modification time
+			if sameContent(localFile, remoteFile) {
+				plan.matching = append(plan.matching, filename)
+				continue
+			}
+			plan.conflictCopies = append(plan.conflictCopies, filename)
+			plan.downloads = append(plan.downloads, remoteFile)
+		case localChanged && remoteDeleted:
+			plan.conflicts = append(plan.conflicts, conflict{filename, "modified locally but deleted on server, keeping local version"})
+			plan.uploads = append(plan.uploads, localFile)
+		case localChanged:
+			if isRemote || !localDeleted {
+				plan.uploads = append(plan.uploads, localFile)
+			}
+		case remoteChanged && isLocal && baseFile.RemoteModTime.IsZero() && sameContent(localFile, remoteFile):
+			// snapshots written before the server modification time was recorded
+			plan.matching = append(plan.matching, filename)
+		case remoteChanged:
+			plan.downloads = append(plan.downloads, remoteFile)
+		case remoteDeleted:
+			if isLocal {
+				plan.localDeletions = append(plan.localDeletions, filename)
+			}
+		}
+	}
+	return plan
+}
+
+// conflict is a path changed on both sides, and how it was resolved
+type conflict struct {
+	path    string
+	message string
+}
+
+func conflictCopyName(filename string) string {
+	extension := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, extension) + ".conflict-" + time.Now().Format("20060102150405") + extension
+}
@@ -0,0 +1,125 @@
+package protocols
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	files "fileTransfer/filesystem"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const syncedFile = "/work/a.txt"
+
+// fileVersion describes a file as "content@minute", or "deleted" for a local
+// file still in the snapshot, "" meaning there's no such file
+func fileVersion(t *testing.T, version string) files.FileData {
+	parts := strings.SplitN(version, "@", 2)
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256([]byte(parts[0]))
+	return files.FileData{AbsolutePath: syncedFile, RelativePath: "a.txt", Size: int64(len(parts[0])),
+		ModTime: time.Date(2026, 1, 1, 0, minute, 0, 0, time.UTC), Hash: hex.EncodeToString(hash[:])}
+}
+
+// actions lists what a plan does, in a fixed order
+func (plan syncPlan) actions() []string {
+	actions := []string{}
+	for _, action := range []struct {
+		name  string
+		count int
+	}{
+		{"upload", len(plan.uploads)},
+		{"download", len(plan.downloads)},
+		{"delete", len(plan.localDeletions)},
+		{"forget", len(plan.forgotten)},
+		{"copy", len(plan.conflictCopies)},
+		{"match", len(plan.matching)},
+		{"conflict", len(plan.conflicts)},
+	} {
+		for i := 0; i < action.count; i++ {
+			actions = append(actions, action.name)
+		}
+	}
+	return actions
+}
+
+func TestPlanSync(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		local   string
+		remote  string
+		legacy  bool // the snapshot doesn't have the server modification time
+		actions []string
+	}{
+		{"unchanged", "v1@1", "v1@1", "v1@1", false, []string{}},
+		{"touched locally", "v1@1", "v1@2", "v1@1", false, []string{}},
+		{"modified locally", "v1@1", "v22@2", "v1@1", false, []string{"upload"}},
+		{"created locally", "", "v1@1", "", false, []string{"upload"}},
+		{"deleted locally", "v1@1", "deleted", "v1@1", false, []string{"upload"}},
+		{"modified on server", "v1@1", "v1@1", "v22@2", false, []string{"download"}},
+		{"touched on server", "v1@1", "v1@1", "v1@2", false, []string{"download"}},
+		{"created on server", "", "", "v1@1", false, []string{"download"}},
+		{"deleted on server", "v1@1", "v1@1", "", false, []string{"delete"}},
+		{"deleted on both sides", "v1@1", "deleted", "", false, []string{"forget"}},
+		{"modified on both sides", "v1@1", "v22@2", "v33@3", false, []string{"download", "copy"}},
+		{"same size on both sides", "v1@1", "v2@2", "v3@3", false, []string{"download", "copy"}},
+		{"same edit on both sides", "v1@1", "v22@2", "v22@3", false, []string{"match"}},
+		{"identical without snapshot", "", "v1@1", "v1@2", false, []string{"match"}},
+		{"different without snapshot", "", "v1@1", "v2@1", false, []string{"download", "copy"}},
+		{"deleted locally, modified on server", "v1@1", "deleted", "v22@2", false, []string{"download", "conflict"}},
+		{"modified locally, deleted on server", "v1@1", "v22@2", "", false, []string{"upload", "conflict"}},
+		{"touched on server since legacy snapshot", "v1@1", "v1@1", "v1@2", true, []string{"match"}},
+		{"modified on server since legacy snapshot", "v1@1", "v1@1", "v2@2", true, []string{"download"}},
+		{"resized on server since legacy snapshot", "v1@1", "v1@1", "v22@2", true, []string{"download"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			baseline, localFiles, remoteFiles := files.Filesystem{}, files.Filesystem{}, files.Filesystem{}
+			if test.base != "" {
+				baseFile := fileVersion(t, test.base)
+				if !test.legacy {
+					baseFile.RemoteModTime = baseFile.ModTime
+				}
+				baseline[syncedFile] = baseFile
+			}
+			switch test.local {
+			case "":
+			case "deleted":
+				localFile := baseline[syncedFile]
+				localFile.IsDeleted = true
+				localFiles[syncedFile] = localFile
+			default:
+				localFiles[syncedFile] = fileVersion(t, test.local)
+			}
+			if test.remote != "" {
+				// servers don't list hashes, sameContent below relies on it for the test only
+				remoteFiles[syncedFile] = fileVersion(t, test.remote)
+			}
+			sameContent := func(localFile files.FileData, remoteFile files.FileData) bool {
+				return localFile.Hash == remoteFile.Hash
+			}
+
+			plan := planSync(baseline, localFiles, remoteFiles, sameContent)
+			if actions := plan.actions(); !reflect.DeepEqual(actions, test.actions) {
+				t.Errorf("planSync() = %v, want %v", actions, test.actions)
+			}
+		})
+	}
+}
+
+func TestPlanSyncSkipsConflictCopies(t *testing.T) {
+	conflictCopy := fileVersion(t, "v1@1")
+	conflictCopy.AbsolutePath = "/work/a.conflict-20260101000000.txt"
+	localFiles := files.Filesystem{conflictCopy.AbsolutePath: conflictCopy}
+
+	plan := planSync(files.Filesystem{}, localFiles, files.Filesystem{}, nil)
+	if actions := plan.actions(); len(actions) > 0 {
+		t.Errorf("planSync() of a conflict copy = %v, want nothing", actions)
+	}
+}
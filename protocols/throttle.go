@@ -0,0 +1,75 @@
+package protocols
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket shared by all the workers transferring in one
+// direction, refilled with rate bytes per second. A nil bucket doesn't limit.
+type bucket struct {
+	mutex  sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// newBucket returns nil when there's no limit, limits being in KiB/s
+func newBucket(limit int) *bucket {
+	if limit <= 0 {
+		return nil
+	}
+	rate := float64(limit) * 1024
+	return &bucket{rate: rate, tokens: rate, last: time.Now()}
+}
+
+// bandwidthLimit returns the limit of a direction, falling back to the global one
+func bandwidthLimit(directionLimit int, globalLimit int) int {
+	if directionLimit > 0 {
+		return directionLimit
+	}
+	return globalLimit
+}
+
+// take removes n tokens, waiting for the bucket to refill if it's empty. Tokens
+// may go below zero so that each caller waits for its own bytes only.
+func (b *bucket) take(n int) {
+	b.mutex.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	wait := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mutex.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// throttledReader takes from the bucket the bytes it reads
+type throttledReader struct {
+	io.Reader
+	bucket *bucket
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	// reads are kept small so that a single call doesn't exceed the burst
+	if len(p) > 32*1024 {
+		p = p[:32*1024]
+	}
+	n, err := r.Reader.Read(p)
+	r.bucket.take(n)
+	return n, err
+}
+
+// throttle wraps reader so that it doesn't go faster than the bucket allows
+func throttle(reader io.Reader, b *bucket) io.Reader {
+	if b == nil {
+		return reader
+	}
+	return &throttledReader{reader, b}
+}
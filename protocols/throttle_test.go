@@ -0,0 +1,82 @@
+package protocols
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestBandwidthLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		direction int
+		global    int
+		expected  int
+	}{
+		{"unlimited", 0, 0, 0},
+		{"global only", 0, 100, 100},
+		{"direction only", 50, 0, 50},
+		{"direction over global", 50, 100, 50},
+		{"direction above global", 200, 100, 200},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if limit := bandwidthLimit(test.direction, test.global); limit != test.expected {
+				t.Errorf("bandwidthLimit(%d, %d) = %d, want %d", test.direction, test.global, limit, test.expected)
+			}
+		})
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int // KiB/s
+		size    int // KiB
+		minimum time.Duration
+	}{
+		// the bucket starts full, a second worth of bytes going through at once
+		{"unlimited", 0, 1024, 0},
+		{"within the burst", 1024, 512, 0},
+		{"half a second over the burst", 1024, 1536, 500 * time.Millisecond},
+		{"a second over the burst", 2048, 4096, time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := newBucket(test.limit)
+			if (b == nil) != (test.limit == 0) {
+				t.Fatalf("newBucket(%d) = %v", test.limit, b)
+			}
+			started := time.Now()
+			n, err := io.Copy(io.Discard, throttle(bytes.NewReader(make([]byte, test.size*1024)), b))
+			elapsed := time.Since(started)
+			if err != nil || n != int64(test.size*1024) {
+				t.Fatalf("copied %d bytes: %v", n, err)
+			}
+			// the last read may wait for less than its own bytes
+			if elapsed < test.minimum-50*time.Millisecond || elapsed > test.minimum+500*time.Millisecond {
+				t.Errorf("copied %d KiB at %d KiB/s in %v, want about %v", test.size, test.limit, elapsed, test.minimum)
+			}
+		})
+	}
+}
+
+func TestBucketShared(t *testing.T) {
+	// workers sharing a bucket go as fast as one alone
+	b := newBucket(1024)
+	started := time.Now()
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		go func() {
+			io.Copy(io.Discard, throttle(bytes.NewReader(make([]byte, 384*1024)), b))
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+	if elapsed := time.Since(started); elapsed < 450*time.Millisecond {
+		t.Errorf("4 workers copied 1536 KiB at 1024 KiB/s in %v, want about 500ms", elapsed)
+	}
+}
@@ -0,0 +1,261 @@
+package protocols
+
+import (
+	"errors"
+	clientConfig "fileTransfer/configuration"
+	files "fileTransfer/filesystem"
+	"fileTransfer/output"
+	"fileTransfer/protocols/transport"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// transferOptions are shared by all the transfers of a run in one direction
+type transferOptions struct {
+	target      string
+	eventTarget string // only set when publishing to several targets at once
+	verify      bool
+	limit       *bucket
+	progress    *progress
+}
+
+// syncer is implemented by remote files that can be flushed to disk
+type syncer interface {
+	Sync() error
+}
+
+// copyFileToLocal downloads into a temporary sibling first, so that the local
+// file is replaced only once the download succeeded, and verified if asked to.
+// The remote modification time is kept to recognise the file as unchanged later on.
+func copyFileToLocal(conn transport.Transport, localFile string, remoteFile files.FileData, options transferOptions) (int64, error) {
+	temporaryFile := files.TemporaryName(localFile)
+	var offset int64
+	resumer, canResume := conn.(transport.Resumer)
+	if info, err := os.Stat(temporaryFile); err == nil && canResume {
+		offset = files.ResumeOffset(options.target, temporaryFile, remoteFile, info.Size(), info.ModTime())
+	}
+	if err := files.StartTransfer(options.target, temporaryFile, remoteFile, false); err != nil {
+		return 0, fmt.Errorf("cannot record transfer of (%s): %w", temporaryFile, err)
+	}
+
+	var destinationFile *os.File
+	var sourceFile io.ReadCloser
+	var err error
+	if offset > 0 {
+		log.Printf("resuming transfer of %s at byte %d\n", remoteFile.AbsolutePath, offset)
+		destinationFile, err = os.OpenFile(temporaryFile, os.O_WRONLY|os.O_APPEND, 0)
+	} else {
+		destinationFile, err = os.Create(temporaryFile)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("cannot open local file (%s): %w", temporaryFile, err)
+	}
+	defer destinationFile.Close()
+
+	if offset > 0 {
+		sourceFile, err = resumer.OpenAt(remoteFile.AbsolutePath, offset)
+	} else {
+		sourceFile, err = conn.Open(remoteFile.AbsolutePath)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("cannot open remote file (%s): %w", remoteFile.AbsolutePath, err)
+	}
+
+	transferred := false
+	file := options.progress.track(remoteFile.RelativePath, remoteFile.AbsolutePath, remoteFile.Size, offset)
+	output.Started(options.eventTarget, "download", remoteFile.AbsolutePath, localFile, remoteFile.Size, offset)
+	defer func() { options.progress.finish(file, transferred) }()
+	bytes, err := io.Copy(&progressWriter{destinationFile, options.progress, file}, throttle(sourceFile, options.limit))
+	// closed right away, the remote connection being needed again to verify the file
+	sourceFile.Close()
+	if err != nil {
+		return 0, fmt.Errorf("cannot copy remote file (%s -> %s): %w", remoteFile.AbsolutePath, temporaryFile, err)
+	}
+
+	err = destinationFile.Sync()
+	if err != nil {
+		return 0, fmt.Errorf("cannot sync local file (%s): %w", temporaryFile, err)
+	}
+	err = destinationFile.Close()
+	if err != nil {
+		return 0, fmt.Errorf("cannot close local file (%s): %w", temporaryFile, err)
+	}
+	info, err := os.Stat(temporaryFile)
+	if err != nil {
+		return 0, fmt.Errorf("cannot verify local file %s: %w", temporaryFile, err)
+	}
+	if info.Size() != offset+bytes {
+		return 0, fmt.Errorf("local file %s has %d bytes instead of %d", temporaryFile, info.Size(), offset+bytes)
+	}
+	if options.verify {
+		if info.Size() != remoteFile.Size {
+			return 0, fmt.Errorf("local file %s has %d bytes instead of %d", temporaryFile, info.Size(), remoteFile.Size)
+		}
+		// a corrupted download can't be resumed
+		if err = verifyChecksum(conn, temporaryFile, remoteFile.AbsolutePath); err != nil {
+			os.Remove(temporaryFile)
+			files.FinishTransfer(options.target, temporaryFile)
+			return 0, err
+		}
+	}
+	if !remoteFile.ModTime.IsZero() {
+		err = os.Chtimes(temporaryFile, time.Now(), remoteFile.ModTime)
+		if err != nil {
+			return 0, fmt.Errorf("cannot set modification time of local file (%s): %w", temporaryFile, err)
+		}
+	}
+	err = os.Rename(temporaryFile, localFile)
+	if err != nil {
+		return 0, fmt.Errorf("cannot rename local file (%s -> %s): %w", temporaryFile, localFile, err)
+	}
+	transferred = true
+	return bytes, files.FinishTransfer(options.target, temporaryFile)
+}
+
+func downloadFile(conn transport.Transport, localFilename string, remoteFile files.FileData, options transferOptions) (int64, error) {
+	localFilePath, _ := filepath.Split(localFilename)
+	if err := os.MkdirAll(localFilePath, os.ModePerm); err != nil {
+		return 0, fmt.Errorf("cannot create folder(s) (%s): %w", localFilePath, err)
+	}
+	copiedBytes, err := copyFileToLocal(conn, localFilename, remoteFile, options)
+	if err != nil {
+		return 0, fmt.Errorf("cannot copy remote file (%s) to local (%s): %w", remoteFile.AbsolutePath, localFilename, err)
+	}
+	log.Printf("transfered file: %s ---> %s [%d bytes copied]\n", remoteFile.AbsolutePath, localFilename, copiedBytes)
+	output.Completed(options.eventTarget, "download", remoteFile.AbsolutePath, localFilename, copiedBytes)
+	return copiedBytes, nil
+}
+
+// copyFileToRemote writes to a temporary sibling first, so that the remote file
+// is replaced only once it's completely uploaded, and verified if asked to. It
+// returns the modification time the server gave to the file.
+func copyFileToRemote(conn transport.Transport, remoteFilename string, localFile files.FileData, options transferOptions) (int64, time.Time, error) {
+	temporaryFilename := files.TemporaryName(remoteFilename)
+	var offset int64
+	resumer, canResume := conn.(transport.Resumer)
+	if info, err := conn.Stat(temporaryFilename); err == nil && canResume {
+		offset = files.ResumeOffset(options.target, temporaryFilename, localFile, info.Size(), info.ModTime())
+	}
+	if err := files.StartTransfer(options.target, temporaryFilename, localFile, true); err != nil {
+		return 0, time.Time{}, fmt.Errorf("cannot record transfer of (%s): %w", temporaryFilename, err)
+	}
+
+	sourceFile, err := os.Open(localFile.AbsolutePath)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("cannot open local file (%s): %w", localFile.AbsolutePath, err)
+	}
+	defer sourceFile.Close()
+
+	var destinationFile io.WriteCloser
+	if offset > 0 {
+		log.Printf("resuming transfer of %s at byte %d\n", localFile.AbsolutePath, offset)
+		if _, err = sourceFile.Seek(offset, io.SeekStart); err != nil {
+			return 0, time.Time{}, fmt.Errorf("cannot seek local file (%s): %w", localFile.AbsolutePath, err)
+		}
+		destinationFile, err = resumer.Append(temporaryFilename)
+	} else {
+		destinationFile, err = conn.Create(temporaryFilename)
+	}
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("cannot create remote file (%s): %w", temporaryFilename, err)
+	}
+
+	transferred := false
+	file := options.progress.track(localFile.RelativePath, localFile.AbsolutePath, localFile.Size, offset)
+	output.Started(options.eventTarget, "upload", localFile.AbsolutePath, remoteFilename, localFile.Size, offset)
+	defer func() { options.progress.finish(file, transferred) }()
+	bytes, err := io.Copy(&progressWriter{destinationFile, options.progress, file}, throttle(sourceFile, options.limit))
+	if err != nil {
+		destinationFile.Close()
+		return 0, time.Time{}, fmt.Errorf("cannot copy local file (%s -> %s): %w", localFile.AbsolutePath, temporaryFilename, err)
+	}
+	if s, ok := destinationFile.(syncer); ok {
+		err = s.Sync()
+		if err != nil {
+			destinationFile.Close()
+			return 0, time.Time{}, fmt.Errorf("cannot sync remote file (%s): %w", temporaryFilename, err)
+		}
+	}
+	err = destinationFile.Close()
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("cannot close remote file (%s): %w", temporaryFilename, err)
+	}
+	info, err := conn.Stat(temporaryFilename)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("cannot verify remote file %s: %w", temporaryFilename, err)
+	}
+	if info.Size() != offset+bytes {
+		return 0, time.Time{}, fmt.Errorf("remote file %s has %d bytes instead of %d", temporaryFilename, info.Size(), offset+bytes)
+	}
+	if options.verify {
+		if info.Size() != localFile.Size {
+			return 0, time.Time{}, fmt.Errorf("remote file %s has %d bytes instead of %d", temporaryFilename, info.Size(), localFile.Size)
+		}
+		// a corrupted upload can't be resumed
+		if err = verifyChecksum(conn, localFile.AbsolutePath, temporaryFilename); err != nil {
+			conn.Remove(temporaryFilename)
+			files.FinishTransfer(options.target, temporaryFilename)
+			return 0, time.Time{}, err
+		}
+	}
+	err = conn.Rename(temporaryFilename, remoteFilename)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("cannot rename remote file (%s -> %s): %w", temporaryFilename, remoteFilename, err)
+	}
+	transferred = true
+	return bytes, info.ModTime(), files.FinishTransfer(options.target, temporaryFilename)
+}
+
+// removeStaleUploads deletes the temporary files of interrupted uploads that
+// can't be resumed by this run, because their source changed or isn't sent anymore
+func removeStaleUploads(conn transport.Transport, config clientConfig.Configuration, filesList []files.FileData) {
+	pending := map[string]files.FileData{}
+	for _, localFile := range filesList {
+		pending[localFile.AbsolutePath] = localFile
+	}
+	for temporaryFilename, transfer := range files.PartialUploads(config.Target) {
+		localFile, ok := pending[transfer.Source]
+		if ok && !localFile.IsDeleted && localFile.Size == transfer.Size && localFile.ModTime.Equal(transfer.ModTime) {
+			continue
+		}
+		if err := conn.Remove(temporaryFilename); err == nil {
+			log.Printf("deleted stale temporary file: %s\n", temporaryFilename)
+		}
+		files.FinishTransfer(config.Target, temporaryFilename)
+	}
+}
+
+func uploadFile(conn transport.Transport, localFile files.FileData, destinationFilename string, options transferOptions) (int64, time.Time, error) {
+	if localFile.IsDeleted {
+		err := conn.Remove(destinationFilename)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			log.Printf("skipping file deletion. File '%s' not found\n", destinationFilename)
+			output.Skipped(options.eventTarget, destinationFilename, "not found on server")
+		case err != nil:
+			return 0, time.Time{}, fmt.Errorf("cannot delete remote file (%s): %w", destinationFilename, err)
+		default:
+			log.Printf("deleted file: %s ---> %s\n", localFile.AbsolutePath, destinationFilename)
+			output.Deleted(options.eventTarget, destinationFilename)
+		}
+		return 0, time.Time{}, nil
+	}
+
+	destinationDirectory, _ := path.Split(destinationFilename)
+	err := conn.MkdirAll(destinationDirectory)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("cannot create folder(s) (%s): %w", destinationDirectory, err)
+	}
+	copiedBytes, remoteModTime, err := copyFileToRemote(conn, destinationFilename, localFile, options)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("cannot copy local file (%s) to remote (%s): %w", localFile.AbsolutePath, destinationFilename, err)
+	}
+	log.Printf("transfered file: %s ---> %s [%d bytes copied]\n", localFile.AbsolutePath, destinationFilename, copiedBytes)
+	output.Completed(options.eventTarget, "upload", localFile.AbsolutePath, destinationFilename, copiedBytes)
+	return copiedBytes, remoteModTime, nil
+}
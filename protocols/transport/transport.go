@@ -0,0 +1,99 @@
+package transport
+
+import (
+	"errors"
+	clientConfig "fileTransfer/configuration"
+	files "fileTransfer/filesystem"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Transport is the set of remote filesystem operations a protocol backend
+// must provide. Remote paths are always slash separated.
+type Transport interface {
+	// List walks root recursively and returns every regular file found.
+	// RelativePath of each entry is relative to root.
+	List(root string) ([]files.FileData, error)
+	Stat(path string) (os.FileInfo, error)
+	Open(path string) (io.ReadCloser, error)
+	Create(path string) (io.WriteCloser, error)
+	MkdirAll(path string) error
+	// Remove fails with an error wrapping os.ErrNotExist if path doesn't exist
+	Remove(path string) error
+	// RemoveDirectory removes path only if it's an empty folder
+	RemoveDirectory(path string) error
+	Rename(oldPath, newPath string) error
+	Close() error
+}
+
+// Resumer is implemented by backends able to continue interrupted transfers
+type Resumer interface {
+	// OpenAt opens path for reading, skipping the first offset bytes
+	OpenAt(path string, offset int64) (io.ReadCloser, error)
+	// Append opens an existing file for writing after its last byte
+	Append(path string) (io.WriteCloser, error)
+}
+
+// ErrHashUnsupported is returned by Hasher when the server can't hash files
+var ErrHashUnsupported = errors.New("remote hashing unsupported")
+
+// Hasher is implemented by backends able to have the server hash a file, so
+// that it doesn't need to be downloaded again to be verified
+type Hasher interface {
+	// Hash returns the hex encoded sha256 of path, or ErrHashUnsupported
+	Hash(path string) (string, error)
+}
+
+// Retrier is implemented by backends that can tell transient errors apart and
+// replace a connection the server dropped
+type Retrier interface {
+	// Retryable tells whether an operation that failed with err may succeed if tried again
+	Retryable(err error) bool
+	// Reconnect dials the server again, only if the current connection is dead
+	Reconnect() error
+}
+
+// Password returns the password of the server. Backends only call it when the
+// server asks for one, as it may prompt the user.
+type Password func() (string, error)
+
+// Dialer opens a new Transport for the given configuration.
+type Dialer func(config clientConfig.Configuration, password Password) (Transport, error)
+
+var dialers = map[string]Dialer{}
+
+// Register makes a backend available under the given protocol name.
+// It is meant to be called from the init function of the backend package.
+func Register(protocol string, dial Dialer) {
+	if _, ok := dialers[protocol]; ok {
+		panic("transport: protocol registered twice: " + protocol)
+	}
+	dialers[protocol] = dial
+}
+
+// Protocols returns the names of all registered protocols, sorted.
+func Protocols() []string {
+	names := []string{}
+	for name := range dialers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func Dial(config clientConfig.Configuration, password Password) (Transport, error) {
+	dial, ok := dialers[config.Protocol]
+	if !ok {
+		return nil, errors.New("Unexpected protocol: " + config.Protocol)
+	}
+	return dial(config, password)
+}
+
+// Rel returns fullPath relative to root, both being slash separated remote paths.
+func Rel(root, fullPath string) string {
+	root = strings.TrimSuffix(path.Clean(root), "/")
+	return strings.TrimPrefix(path.Clean(fullPath), root+"/")
+}
@@ -0,0 +1,66 @@
+package protocols
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	files "fileTransfer/filesystem"
+	"fileTransfer/protocols/transport"
+	"fmt"
+	"io"
+	"log"
+)
+
+// errChecksumMismatch is retried, the transfer starting over from scratch
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// remoteHash has the server hash a remote file, or downloads it again to hash
+// it when the server can't
+func remoteHash(conn transport.Transport, remoteFilename string) (string, error) {
+	if hasher, ok := conn.(transport.Hasher); ok {
+		hash, err := hasher.Hash(remoteFilename)
+		if !errors.Is(err, transport.ErrHashUnsupported) {
+			return hash, err
+		}
+	}
+	remoteFile, err := conn.Open(remoteFilename)
+	if err != nil {
+		return "", err
+	}
+	defer remoteFile.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, remoteFile); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// sameContent tells whether a local and a remote file hold the same bytes. A
+// remote file that cannot be hashed is considered different.
+func sameContent(conn transport.Transport, localFile files.FileData, remoteFile files.FileData) bool {
+	if localFile.Size != remoteFile.Size {
+		return false
+	}
+	hash, err := remoteHash(conn, remoteFile.AbsolutePath)
+	if err != nil {
+		log.Printf("cannot hash remote file (%s): %v\n", remoteFile.AbsolutePath, err)
+		return false
+	}
+	return hash == localFile.Hash
+}
+
+// verifyChecksum compares the sha256 of a local file with the one of a remote file
+func verifyChecksum(conn transport.Transport, localFilename string, remoteFilename string) error {
+	localHash, err := files.FileHash(localFilename)
+	if err != nil {
+		return fmt.Errorf("cannot hash local file (%s): %w", localFilename, err)
+	}
+	remoteHash, err := remoteHash(conn, remoteFilename)
+	if err != nil {
+		return fmt.Errorf("cannot hash remote file (%s): %w", remoteFilename, err)
+	}
+	if localHash != remoteHash {
+		return fmt.Errorf("%w between local file (%s) and remote file (%s)", errChecksumMismatch, localFilename, remoteFilename)
+	}
+	return nil
+}
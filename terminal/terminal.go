@@ -3,14 +3,16 @@ package terminal
 import (
 	"fmt"
 	"log"
+	"os"
 	"strings"
 	"syscall"
 
 	"golang.org/x/term"
 )
 
-func InputPassword() string {
-	fmt.Println("Password:")
+// InputPassword prompts on stderr, which keeps stdout for the output of the command
+func InputPassword(prompt string) string {
+	fmt.Fprintln(os.Stderr, prompt)
 	passwordInBytes, err := term.ReadPassword(int(syscall.Stdin))
 	if err != nil {
 		log.Fatal(err)
@@ -19,9 +21,14 @@ func InputPassword() string {
 }
 
 func Confirm(message string) bool {
-	fmt.Println(message, "(yes/y/no/n)")
+	fmt.Fprintln(os.Stderr, message, "(yes/y/no/n)")
 	var confirmation string
 	fmt.Scanf("%s", &confirmation)
 	confirmation = strings.ToLower(confirmation)
 	return (confirmation == "yes" || confirmation == "y")
 }
+
+// IsTerminal tells whether f is attached to a terminal, rather than redirected
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}